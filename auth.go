@@ -0,0 +1,221 @@
+package httpclient
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// TokenSource provides a credential, e.g. a bearer token, password or API key. It is called for every request,
+// so credentials can be rotated. Implementations must be safe for concurrent use.
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// StaticToken is a TokenSource that always returns the same credential
+type StaticToken string
+
+// Token returns the token
+func (t StaticToken) Token(_ context.Context) (string, error) {
+	return string(t), nil
+}
+
+// TokenSourceFunc is an adapter to allow the use of ordinary functions as a TokenSource
+type TokenSourceFunc func(ctx context.Context) (string, error)
+
+// Token calls f(ctx)
+func (f TokenSourceFunc) Token(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+// Credentials adds credentials to a request
+type Credentials interface {
+	Apply(req *http.Request) error
+}
+
+// BearerToken adds an "Authorization: Bearer <token>" header to the request
+type BearerToken struct {
+	Source TokenSource
+}
+
+// Apply adds the bearer token to the request
+func (b BearerToken) Apply(req *http.Request) error {
+	token, err := b.Source.Token(req.Context())
+	if err == nil {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return err
+}
+
+// BasicAuth adds basic authentication to the request
+type BasicAuth struct {
+	Username string
+	Password TokenSource
+}
+
+// Apply adds basic authentication to the request
+func (b BasicAuth) Apply(req *http.Request) error {
+	password, err := b.Password.Token(req.Context())
+	if err == nil {
+		req.SetBasicAuth(b.Username, password)
+	}
+	return err
+}
+
+// APIKey adds an API key to the request, either as a header or as a query parameter
+type APIKey struct {
+	// Name is the name of the header or query parameter. Default: X-Api-Key
+	Name string
+	// InQuery adds the key as a query parameter, rather than as a header
+	InQuery bool
+	// Source provides the key
+	Source TokenSource
+}
+
+// Apply adds the API key to the request
+func (a APIKey) Apply(req *http.Request) error {
+	key, err := a.Source.Token(req.Context())
+	if err != nil {
+		return err
+	}
+	name := a.Name
+	if name == "" {
+		name = "X-Api-Key"
+	}
+	if !a.InQuery {
+		req.Header.Set(name, key)
+		return nil
+	}
+	query := req.URL.Query()
+	query.Set(name, key)
+	req.URL.RawQuery = query.Encode()
+	return nil
+}
+
+// Headers adds static headers to the request
+type Headers http.Header
+
+// Apply adds the headers to the request
+func (h Headers) Apply(req *http.Request) error {
+	for name, values := range h {
+		req.Header[http.CanonicalHeaderKey(name)] = append([]string(nil), values...)
+	}
+	return nil
+}
+
+// Authenticator implements the Caller interface. It adds credentials to each request sent to one of the configured Hosts.
+// Authenticator modifies a clone of the request, so the original request never contains credentials.
+//
+// Place the Authenticator as close to the base of the chain as possible: behind a Cacher, so credentials in query
+// parameters don't end up in the cache key, and behind a Retrier, so each attempt gets fresh credentials.
+//
+// Credentials never follow a redirect to a host outside Hosts (or, if Hosts is empty, to a host other than the request's):
+// BaseClient removes the headers and query parameters added by the Authenticator from such redirects. Callers other than
+// BaseClient that follow redirects should disable them, or use Authenticator in a Transport, so each redirect passes through
+// the Authenticator.
+type Authenticator struct {
+	Caller
+	// Credentials adds the credentials to the request
+	Credentials Credentials
+	// Hosts lists the hosts that receive credentials. An entry can be a host name ("example.com"), a host and port ("example.com:8443"),
+	// or a wildcard domain ("*.example.com"). If empty, credentials are added to requests for any host.
+	Hosts []string
+}
+
+var _ Caller = &Authenticator{}
+
+// Do adds the credentials to the request, if its host is allowed, and sends it
+func (a *Authenticator) Do(req *http.Request) (*http.Response, error) {
+	if !a.allowed(req.URL, req.URL.Host) {
+		return a.Caller.Do(req)
+	}
+	clone := req.Clone(req.Context())
+	if clone.Header == nil {
+		clone.Header = make(http.Header)
+	}
+	if err := a.Credentials.Apply(clone); err != nil {
+		return nil, err
+	}
+	guard := redirectGuard{
+		allowed: func(u *url.URL) bool { return a.allowed(u, req.URL.Host) },
+		headers: addedHeaders(req.Header, clone.Header),
+		params:  addedParams(req.URL.Query(), clone.URL.Query()),
+	}
+	clone = clone.WithContext(withRedirectGuard(clone.Context(), guard))
+	return a.Caller.Do(clone)
+}
+
+// allowed returns true if u's host receives credentials. If no Hosts are configured, only origin receives credentials.
+func (a *Authenticator) allowed(u *url.URL, origin string) bool {
+	host := strings.ToLower(u.Host)
+	if len(a.Hosts) == 0 {
+		return host == strings.ToLower(origin)
+	}
+	hostname := strings.ToLower(u.Hostname())
+	for _, allowed := range a.Hosts {
+		allowed = strings.ToLower(allowed)
+		switch {
+		case allowed == host || allowed == hostname:
+			return true
+		case strings.HasPrefix(allowed, "*.") && strings.HasSuffix(hostname, allowed[1:]):
+			return true
+		}
+	}
+	return false
+}
+
+func addedHeaders(before, after http.Header) []string {
+	var names []string
+	for name, values := range after {
+		if !slices.Equal(before[name], values) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func addedParams(before, after url.Values) []string {
+	var names []string
+	for name, values := range after {
+		if !slices.Equal(before[name], values) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// redirectGuard describes the credentials added by an Authenticator, so BaseClient can remove them from redirects to other hosts
+type redirectGuard struct {
+	allowed func(u *url.URL) bool
+	headers []string
+	params  []string
+}
+
+type redirectGuardsKey struct{}
+
+func withRedirectGuard(ctx context.Context, guard redirectGuard) context.Context {
+	guards, _ := ctx.Value(redirectGuardsKey{}).([]redirectGuard)
+	return context.WithValue(ctx, redirectGuardsKey{}, append(slices.Clip(guards), guard))
+}
+
+// stripCredentials removes any credentials from a redirect to a host that shouldn't receive them
+func stripCredentials(req *http.Request) {
+	guards, _ := req.Context().Value(redirectGuardsKey{}).([]redirectGuard)
+	for _, guard := range guards {
+		if guard.allowed(req.URL) {
+			continue
+		}
+		for _, name := range guard.headers {
+			req.Header.Del(name)
+		}
+		if len(guard.params) > 0 {
+			query := req.URL.Query()
+			for _, name := range guard.params {
+				query.Del(name)
+			}
+			req.URL.RawQuery = query.Encode()
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package httpclient_test
+
+import (
+	"context"
+	"errors"
+	"github.com/clambin/cache"
+	"github.com/clambin/httpclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuthenticator_Do(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		credentials httpclient.Credentials
+		check       func(t *testing.T, req *http.Request)
+	}{
+		{
+			name:        "bearer",
+			credentials: httpclient.BearerToken{Source: httpclient.StaticToken("secret")},
+			check: func(t *testing.T, req *http.Request) {
+				assert.Equal(t, "Bearer secret", req.Header.Get("Authorization"))
+			},
+		},
+		{
+			name:        "basic",
+			credentials: httpclient.BasicAuth{Username: "user", Password: httpclient.StaticToken("secret")},
+			check: func(t *testing.T, req *http.Request) {
+				username, password, ok := req.BasicAuth()
+				require.True(t, ok)
+				assert.Equal(t, "user", username)
+				assert.Equal(t, "secret", password)
+			},
+		},
+		{
+			name:        "api key header",
+			credentials: httpclient.APIKey{Source: httpclient.StaticToken("secret")},
+			check: func(t *testing.T, req *http.Request) {
+				assert.Equal(t, "secret", req.Header.Get("X-Api-Key"))
+			},
+		},
+		{
+			name:        "api key query",
+			credentials: httpclient.APIKey{Name: "key", InQuery: true, Source: httpclient.StaticToken("secret")},
+			check: func(t *testing.T, req *http.Request) {
+				assert.Equal(t, "secret", req.URL.Query().Get("key"))
+				assert.Equal(t, "bar", req.URL.Query().Get("foo"))
+			},
+		},
+		{
+			name:        "headers",
+			credentials: httpclient.Headers{"X-Tenant": {"foo"}},
+			check: func(t *testing.T, req *http.Request) {
+				assert.Equal(t, "foo", req.Header.Get("X-Tenant"))
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var received *http.Request
+			c := &httpclient.Authenticator{
+				Caller: httpclient.CallerFunc(func(req *http.Request) (*http.Response, error) {
+					received = req
+					return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+				}),
+				Credentials: tc.credentials,
+				Hosts:       []string{"example.com"},
+			}
+
+			req, _ := http.NewRequest(http.MethodGet, "https://example.com/foo?foo=bar", nil)
+			_, err := c.Do(req)
+			require.NoError(t, err)
+			tc.check(t, received)
+			assert.Empty(t, req.Header, "original request should not be modified")
+			assert.Equal(t, "foo=bar", req.URL.RawQuery, "original request should not be modified")
+		})
+	}
+}
+
+func TestAuthenticator_Do_Hosts(t *testing.T) {
+	var authorization string
+	c := &httpclient.Authenticator{
+		Caller: httpclient.CallerFunc(func(req *http.Request) (*http.Response, error) {
+			authorization = req.Header.Get("Authorization")
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+		}),
+		Credentials: httpclient.BearerToken{Source: httpclient.StaticToken("secret")},
+		Hosts:       []string{"example.com", "*.example.org", "localhost:8443"},
+	}
+
+	for _, tc := range []struct {
+		url  string
+		want bool
+	}{
+		{url: "https://example.com/", want: true},
+		{url: "https://EXAMPLE.com:443/", want: true},
+		{url: "https://api.example.org/", want: true},
+		{url: "https://localhost:8443/", want: true},
+		{url: "https://localhost:8080/", want: false},
+		{url: "https://example.net/", want: false},
+		{url: "https://evilexample.com/", want: false},
+	} {
+		t.Run(tc.url, func(t *testing.T) {
+			authorization = ""
+			req, _ := http.NewRequest(http.MethodGet, tc.url, nil)
+			_, err := c.Do(req)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, authorization != "")
+		})
+	}
+}
+
+func TestAuthenticator_Do_Rotation(t *testing.T) {
+	var count int
+	source := httpclient.TokenSourceFunc(func(_ context.Context) (string, error) {
+		count++
+		if count > 2 {
+			return "", errors.New("token expired")
+		}
+		return "token-" + string(rune('0'+count)), nil
+	})
+	var tokens []string
+	c := &httpclient.Authenticator{
+		Caller: httpclient.CallerFunc(func(req *http.Request) (*http.Response, error) {
+			tokens = append(tokens, req.Header.Get("Authorization"))
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+		}),
+		Credentials: httpclient.BearerToken{Source: source},
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "https://example.com/", nil)
+	for i := 0; i < 2; i++ {
+		_, err := c.Do(req)
+		require.NoError(t, err)
+	}
+	_, err := c.Do(req)
+	require.Error(t, err)
+	assert.Equal(t, []string{"Bearer token-1", "Bearer token-2"}, tokens)
+}
+
+func TestAuthenticator_Redirect(t *testing.T) {
+	var leaked string
+	thirdParty := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		leaked = req.Header.Get("X-Api-Key")
+	}))
+	defer thirdParty.Close()
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.Redirect(w, req, thirdParty.URL, http.StatusFound)
+	}))
+	defer upstream.Close()
+
+	u, _ := url.Parse(upstream.URL)
+	c := &http.Client{Transport: &httpclient.Transport{Caller: httpclient.Chain(&httpclient.RoundTripperCaller{},
+		httpclient.CachingMiddleware(nil, cache.New[string, []byte](time.Minute, 0)),
+		func(next httpclient.Caller) httpclient.Caller {
+			return &httpclient.Authenticator{
+				Caller:      next,
+				Credentials: httpclient.APIKey{Source: httpclient.StaticToken("secret")},
+				Hosts:       []string{u.Host},
+			}
+		},
+	)}}
+
+	resp, err := c.Get(upstream.URL)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Empty(t, leaked)
+}
+
+func TestAuthenticator_Do_CrossHostRedirect(t *testing.T) {
+	var received http.Header
+	var receivedQuery url.Values
+	thirdParty := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		received, receivedQuery = req.Header.Clone(), req.URL.Query()
+	}))
+	defer thirdParty.Close()
+	// redirect to the same server, but under a different host name
+	thirdPartyURL := strings.Replace(thirdParty.URL, "127.0.0.1", "localhost", 1)
+
+	var sameHost http.Header
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/same":
+			sameHost = req.Header.Clone()
+		case "/local":
+			http.Redirect(w, req, "/same", http.StatusFound)
+		default:
+			http.Redirect(w, req, thirdPartyURL+"/?"+req.URL.RawQuery, http.StatusFound)
+		}
+	}))
+	defer upstream.Close()
+	u, _ := url.Parse(upstream.URL)
+
+	for _, tc := range []struct {
+		name        string
+		credentials httpclient.Credentials
+		hosts       []string
+	}{
+		{name: "api key", credentials: httpclient.APIKey{Source: httpclient.StaticToken("secret")}, hosts: []string{u.Hostname()}},
+		{name: "api key in query", credentials: httpclient.APIKey{Name: "key", InQuery: true, Source: httpclient.StaticToken("secret")}, hosts: []string{u.Host}},
+		{name: "headers", credentials: httpclient.Headers{"X-Tenant": {"secret"}}},
+		{name: "bearer", credentials: httpclient.BearerToken{Source: httpclient.StaticToken("secret")}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			received, receivedQuery, sameHost = nil, nil, nil
+			c := &httpclient.Authenticator{Caller: &httpclient.BaseClient{}, Credentials: tc.credentials, Hosts: tc.hosts}
+
+			req, _ := http.NewRequest(http.MethodGet, upstream.URL+"/foo?foo=bar", nil)
+			resp, err := c.Do(req)
+			require.NoError(t, err)
+			_ = resp.Body.Close()
+			require.NotNil(t, received, "redirect not followed")
+			for name, values := range received {
+				assert.NotContains(t, values, "secret", name)
+				assert.NotContains(t, values, "Bearer secret", name)
+			}
+			assert.Empty(t, receivedQuery.Get("key"))
+			assert.Equal(t, "bar", receivedQuery.Get("foo"))
+
+			// redirects to the same host keep the credentials
+			req, _ = http.NewRequest(http.MethodGet, upstream.URL+"/local", nil)
+			resp, err = c.Do(req)
+			require.NoError(t, err)
+			_ = resp.Body.Close()
+			require.NotNil(t, sameHost)
+			if tc.name != "api key in query" {
+				var found bool
+				for _, values := range sameHost {
+					found = found || slices.Contains(values, "secret") || slices.Contains(values, "Bearer secret")
+				}
+				assert.True(t, found)
+			}
+		})
+	}
+}
+
+func TestAuthenticator_Do_RedirectPolicy(t *testing.T) {
+	var calls int
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		calls++
+		http.Redirect(w, req, "/loop", http.StatusFound)
+	}))
+	defer s.Close()
+
+	// the http.Client's own redirect policy still applies
+	c := &httpclient.Authenticator{
+		Caller: &httpclient.BaseClient{HTTPClient: &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}}},
+		Credentials: httpclient.APIKey{Source: httpclient.StaticToken("secret")},
+	}
+	req, _ := http.NewRequest(http.MethodGet, s.URL, nil)
+	resp, err := c.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+	assert.Equal(t, 1, calls)
+
+	// without a policy, redirects stop after 10
+	calls = 0
+	c.Caller = &httpclient.BaseClient{}
+	_, err = c.Do(req)
+	assert.ErrorContains(t, err, "stopped after 10 redirects")
+	assert.Equal(t, 10, calls)
+}
@@ -0,0 +1,222 @@
+package httpclient
+
+import (
+	"errors"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrConcurrencyLimitExceeded is the sentinel error for ConcurrencyLimitError: errors.Is(err, ErrConcurrencyLimitExceeded) reports whether
+// a request was rejected by a ConcurrencyLimiter.
+var ErrConcurrencyLimitExceeded = errors.New("concurrency limit exceeded")
+
+// ConcurrencyLimitError is returned by ConcurrencyLimiter when it rejects a request.
+type ConcurrencyLimitError struct {
+	// Key identifies the upstream
+	Key string
+	// Timeout indicates the request waited QueueTimeout in the queue. Otherwise, the queue was full.
+	Timeout bool
+}
+
+// Error returns the error message
+func (e *ConcurrencyLimitError) Error() string {
+	reason := "queue full"
+	if e.Timeout {
+		reason = "queue timeout"
+	}
+	return fmt.Sprintf("concurrency limit exceeded for %s: %s", e.Key, reason)
+}
+
+// Unwrap returns ErrConcurrencyLimitExceeded
+func (e *ConcurrencyLimitError) Unwrap() error {
+	return ErrConcurrencyLimitExceeded
+}
+
+// ConcurrencyLimiter implements the Caller interface. It limits the number of in-flight requests per key (by default, the request's host),
+// so that a slow upstream can't use up all goroutines and connections.
+//
+// A request is in-flight until its response body has been read completely, or is closed. If all slots are in use, up to MaxQueue requests
+// wait for a slot, for at most QueueTimeout. Other requests are rejected with a ConcurrencyLimitError.
+type ConcurrencyLimiter struct {
+	Caller
+	// MaxConcurrent is the maximum number of in-flight requests per key. Default: 10
+	MaxConcurrent int
+	// MaxQueue is the maximum number of requests per key waiting for a slot. Zero rejects requests as soon as all slots are in use.
+	MaxQueue int
+	// QueueTimeout is the maximum time a request waits for a slot. Zero waits until the request's context is done.
+	QueueTimeout time.Duration
+	// KeyFunc determines the key of a request. If nil, the request's host is used.
+	KeyFunc func(req *http.Request) string
+	// Metrics records the number of active and queued requests. Optional.
+	Metrics *ConcurrencyMetrics
+	// Application is used as the application label in Metrics.
+	Application string
+
+	lock       sync.Mutex
+	semaphores map[string]*semaphore
+}
+
+var _ Caller = &ConcurrencyLimiter{}
+
+const defaultMaxConcurrent = 10
+
+type semaphore struct {
+	slots  chan struct{}
+	queued int
+}
+
+// Do sends the request once a slot is available
+func (l *ConcurrencyLimiter) Do(req *http.Request) (*http.Response, error) {
+	key := l.key(req)
+	s := l.semaphore(key)
+
+	if err := l.acquire(req, key, s); err != nil {
+		return nil, err
+	}
+	l.Metrics.reportActive(l.Application, key, 1)
+
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			<-s.slots
+			l.Metrics.reportActive(l.Application, key, -1)
+		})
+	}
+
+	resp, err := l.Caller.Do(req)
+	if err != nil {
+		release()
+		return resp, err
+	}
+	resp.Body = &instrumentedBody{ReadCloser: resp.Body, onDone: func(error) { release() }}
+	return resp, nil
+}
+
+func (l *ConcurrencyLimiter) acquire(req *http.Request, key string, s *semaphore) error {
+	select {
+	case s.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	l.lock.Lock()
+	if s.queued >= l.MaxQueue {
+		l.lock.Unlock()
+		l.Metrics.reportRejected(l.Application, key)
+		return &ConcurrencyLimitError{Key: key}
+	}
+	s.queued++
+	l.lock.Unlock()
+	l.Metrics.reportQueued(l.Application, key, 1)
+
+	defer func() {
+		l.lock.Lock()
+		s.queued--
+		l.lock.Unlock()
+		l.Metrics.reportQueued(l.Application, key, -1)
+	}()
+
+	var timeout <-chan time.Time
+	if l.QueueTimeout > 0 {
+		timer := time.NewTimer(l.QueueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case s.slots <- struct{}{}:
+		return nil
+	case <-timeout:
+		l.Metrics.reportRejected(l.Application, key)
+		return &ConcurrencyLimitError{Key: key, Timeout: true}
+	case <-req.Context().Done():
+		return req.Context().Err()
+	}
+}
+
+func (l *ConcurrencyLimiter) key(req *http.Request) string {
+	if l.KeyFunc == nil {
+		return req.URL.Host
+	}
+	return l.KeyFunc(req)
+}
+
+func (l *ConcurrencyLimiter) semaphore(key string) *semaphore {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.semaphores == nil {
+		l.semaphores = make(map[string]*semaphore)
+	}
+	s, ok := l.semaphores[key]
+	if !ok {
+		maxConcurrent := l.MaxConcurrent
+		if maxConcurrent <= 0 {
+			maxConcurrent = defaultMaxConcurrent
+		}
+		s = &semaphore{slots: make(chan struct{}, maxConcurrent)}
+		l.semaphores[key] = s
+	}
+	return s
+}
+
+// ConcurrencyMetrics contains Prometheus metrics recorded by ConcurrencyLimiter.
+type ConcurrencyMetrics struct {
+	active   *prometheus.GaugeVec   // number of in-flight requests
+	queued   *prometheus.GaugeVec   // number of requests waiting for a slot
+	rejected *prometheus.CounterVec // number of rejected requests
+}
+
+// NewConcurrencyMetrics creates the Prometheus metrics recorded by ConcurrencyLimiter.
+func NewConcurrencyMetrics(namespace, subsystem string) *ConcurrencyMetrics {
+	return &ConcurrencyMetrics{
+		active: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: prometheus.BuildFQName(namespace, subsystem, "api_active_requests"),
+			Help: "Number of in-flight API calls",
+		}, []string{"application", "key"}),
+		queued: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: prometheus.BuildFQName(namespace, subsystem, "api_queued_requests"),
+			Help: "Number of API calls waiting for a slot",
+		}, []string{"application", "key"}),
+		rejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: prometheus.BuildFQName(namespace, subsystem, "api_concurrency_rejected_total"),
+			Help: "Number of API calls rejected by the concurrency limiter",
+		}, []string{"application", "key"}),
+	}
+}
+
+var _ prometheus.Collector = &ConcurrencyMetrics{}
+
+// Describe implements the prometheus.Collector interface so clients can register ConcurrencyMetrics as a whole
+func (m *ConcurrencyMetrics) Describe(ch chan<- *prometheus.Desc) {
+	m.active.Describe(ch)
+	m.queued.Describe(ch)
+	m.rejected.Describe(ch)
+}
+
+// Collect implements the prometheus.Collector interface so clients can register ConcurrencyMetrics as a whole
+func (m *ConcurrencyMetrics) Collect(ch chan<- prometheus.Metric) {
+	m.active.Collect(ch)
+	m.queued.Collect(ch)
+	m.rejected.Collect(ch)
+}
+
+func (m *ConcurrencyMetrics) reportActive(application, key string, delta float64) {
+	if m != nil {
+		m.active.WithLabelValues(application, key).Add(delta)
+	}
+}
+
+func (m *ConcurrencyMetrics) reportQueued(application, key string, delta float64) {
+	if m != nil {
+		m.queued.WithLabelValues(application, key).Add(delta)
+	}
+}
+
+func (m *ConcurrencyMetrics) reportRejected(application, key string) {
+	if m != nil {
+		m.rejected.WithLabelValues(application, key).Inc()
+	}
+}
@@ -0,0 +1,121 @@
+package httpclient_test
+
+import (
+	"context"
+	"github.com/clambin/httpclient"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConcurrencyLimiter_Do(t *testing.T) {
+	proceed := make(chan struct{})
+	metrics := httpclient.NewConcurrencyMetrics("foo", "")
+	r := prometheus.NewRegistry()
+	r.MustRegister(metrics)
+	c := &httpclient.ConcurrencyLimiter{
+		Caller: httpclient.CallerFunc(func(req *http.Request) (*http.Response, error) {
+			<-proceed
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("ok"))}, nil
+		}),
+		MaxConcurrent: 1,
+		MaxQueue:      1,
+		QueueTimeout:  200 * time.Millisecond,
+		Metrics:       metrics,
+		Application:   "foo",
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/foo", nil)
+	type result struct {
+		resp *http.Response
+		err  error
+	}
+	first := make(chan result)
+	go func() {
+		resp, err := c.Do(req)
+		first <- result{resp, err}
+	}()
+	assert.Eventually(t, func() bool { return metricValue(t, r, "foo_api_active_requests") == 1 }, time.Second, 10*time.Millisecond)
+
+	second := make(chan error)
+	go func() {
+		_, err := c.Do(req)
+		second <- err
+	}()
+	assert.Eventually(t, func() bool { return metricValue(t, r, "foo_api_queued_requests") == 1 }, time.Second, 10*time.Millisecond)
+
+	// queue is full
+	_, err := c.Do(req)
+	var limitErr *httpclient.ConcurrencyLimitError
+	require.ErrorAs(t, err, &limitErr)
+	assert.False(t, limitErr.Timeout)
+	assert.Equal(t, "example.com", limitErr.Key)
+
+	// queued request times out
+	err = <-second
+	require.ErrorIs(t, err, httpclient.ErrConcurrencyLimitExceeded)
+	require.ErrorAs(t, err, &limitErr)
+	assert.True(t, limitErr.Timeout)
+	assert.Equal(t, float64(0), metricValue(t, r, "foo_api_queued_requests"))
+
+	// slot is released once the body is closed
+	close(proceed)
+	res := <-first
+	require.NoError(t, res.err)
+	assert.Equal(t, float64(1), metricValue(t, r, "foo_api_active_requests"))
+	_, _ = io.ReadAll(res.resp.Body)
+	_ = res.resp.Body.Close()
+	assert.Equal(t, float64(0), metricValue(t, r, "foo_api_active_requests"))
+
+	resp, err := c.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	assert.Equal(t, float64(2), metricValue(t, r, "foo_api_concurrency_rejected_total"))
+}
+
+func TestConcurrencyLimiter_Do_Cancelled(t *testing.T) {
+	c := &httpclient.ConcurrencyLimiter{
+		Caller: httpclient.CallerFunc(func(req *http.Request) (*http.Response, error) {
+			<-req.Context().Done()
+			return nil, req.Context().Err()
+		}),
+		MaxConcurrent: 1,
+		MaxQueue:      1,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://example.com/foo", nil)
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := c.Do(req)
+			errs <- err
+		}()
+	}
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	for i := 0; i < 2; i++ {
+		assert.ErrorIs(t, <-errs, context.Canceled)
+	}
+}
+
+func metricValue(t *testing.T, g prometheus.Gatherer, name string) float64 {
+	t.Helper()
+	m, err := g.Gather()
+	require.NoError(t, err)
+	var value float64
+	for _, entry := range m {
+		if entry.GetName() == name {
+			for _, metric := range entry.Metric {
+				value += metric.GetGauge().GetValue() + metric.GetCounter().GetValue()
+			}
+		}
+	}
+	return value
+}
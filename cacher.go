@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"github.com/clambin/cache"
+	"go.opentelemetry.io/otel/trace"
 	"net/http"
 	"net/http/httputil"
 	"time"
@@ -19,6 +20,8 @@ type Cacher struct {
 var _ Caller = &Cacher{}
 
 // NewCacher creates a new Cacher.  It will also use InstrumentedClient to measure API call performance statistics.
+//
+// Deprecated: use New with WithCache, WithCacheTable and WithMetrics.
 func NewCacher(httpClient *http.Client, application string, options Options, cacheEntries []CacheTableEntry, cacheExpiry, cacheCleanup time.Duration) *Cacher {
 	return &Cacher{
 		Caller: &InstrumentedClient{
@@ -35,9 +38,14 @@ func NewCacher(httpClient *http.Client, application string, options Options, cac
 // If a (non-expired) cached response exists for the request's URL, it is returned instead.
 //
 // Note: only the request's URL is used to find a cached version. Currently, it does not consider the request's method (i.e. GET/PUT/etc).
+// Server-Sent Events streams (see IsEventStream) are never cached.
 func (c *Cacher) Do(req *http.Request) (resp *http.Response, err error) {
+	if IsEventStream(req.Header) {
+		return c.Caller.Do(req)
+	}
 	key := cacheKey(req)
 	body, found := c.Cache.Get(key)
+	reportCacheOutcome(req, found)
 	if found {
 		return cachedResponse(body, req)
 	}
@@ -49,7 +57,7 @@ func (c *Cacher) Do(req *http.Request) (resp *http.Response, err error) {
 	}
 
 	shouldCache, expiry := c.shouldCache(req)
-	if !shouldCache {
+	if !shouldCache || IsEventStream(resp.Header) {
 		return
 	}
 
@@ -78,3 +86,15 @@ func cachedResponse(b []byte, r *http.Request) (resp *http.Response, err error)
 	buf := bytes.NewBuffer(b)
 	return http.ReadResponse(bufio.NewReader(buf), r)
 }
+
+// reportCacheOutcome adds a cache hit/miss event to the request's span, if any, and records it for LoggingClient.
+func reportCacheOutcome(r *http.Request, hit bool) {
+	if outcome, ok := r.Context().Value(cacheOutcomeKey{}).(*cacheOutcome); ok {
+		outcome.record(hit)
+	}
+	event := "cache miss"
+	if hit {
+		event = "cache hit"
+	}
+	trace.SpanFromContext(r.Context()).AddEvent(event)
+}
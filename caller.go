@@ -1,6 +1,7 @@
 package httpclient
 
 import (
+	"errors"
 	"net/http"
 	"sync"
 )
@@ -20,12 +21,27 @@ type BaseClient struct {
 
 var _ Caller = &BaseClient{}
 
-// Do performs the actual HTTP request
+// Do performs the actual HTTP request. If an Authenticator added credentials to the request, they are removed from any
+// redirect to a host that shouldn't receive them.
 func (b *BaseClient) Do(req *http.Request) (resp *http.Response, err error) {
 	b.lock.Lock()
 	if b.HTTPClient == nil {
 		b.HTTPClient = http.DefaultClient
 	}
 	b.lock.Unlock()
-	return b.HTTPClient.Do(req)
+	if _, ok := req.Context().Value(redirectGuardsKey{}).([]redirectGuard); !ok {
+		return b.HTTPClient.Do(req)
+	}
+	client := *b.HTTPClient
+	client.CheckRedirect = func(redirect *http.Request, via []*http.Request) error {
+		stripCredentials(redirect)
+		if b.HTTPClient.CheckRedirect != nil {
+			return b.HTTPClient.CheckRedirect(redirect, via)
+		}
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		return nil
+	}
+	return client.Do(req)
 }
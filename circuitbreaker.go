@@ -0,0 +1,378 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// CircuitState is the state of a circuit in a CircuitBreaker
+type CircuitState int
+
+const (
+	// CircuitClosed means requests are passed on as normal
+	CircuitClosed CircuitState = iota
+	// CircuitOpen means requests fail immediately with a CircuitOpenError
+	CircuitOpen
+	// CircuitHalfOpen means a limited number of requests are passed on, to probe whether the upstream has recovered
+	CircuitHalfOpen
+)
+
+// String returns the name of the state
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// ErrCircuitOpen is the sentinel error for CircuitOpenError: errors.Is(err, ErrCircuitOpen) reports whether a request was rejected by a CircuitBreaker.
+var ErrCircuitOpen = errors.New("circuit open")
+
+// CircuitOpenError is returned by CircuitBreaker when it rejects a request because the request's circuit is open.
+type CircuitOpenError struct {
+	// Key identifies the circuit
+	Key string
+	// RetryAfter is the remaining time before the circuit allows probe requests
+	RetryAfter time.Duration
+}
+
+// Error returns the error message
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("circuit open for %s: retry after %s", e.Key, e.RetryAfter)
+}
+
+// Unwrap returns ErrCircuitOpen
+func (e *CircuitOpenError) Unwrap() error {
+	return ErrCircuitOpen
+}
+
+// CircuitBreaker implements the Caller interface. It keeps a circuit per key (by default, the request's host).
+//
+// While a circuit is closed, CircuitBreaker passes requests on and counts failures. Once ConsecutiveFailures is reached,
+// or the failure ratio (measured over at least MinRequests requests) reaches FailureRatio, the circuit opens.
+// While open, requests fail immediately with a CircuitOpenError. After Cooldown, the circuit goes half-open and lets
+// HalfOpenProbes requests through. If these all succeed, the circuit closes. If any of them fails, the circuit opens again.
+//
+// Requests that fail because their context was cancelled are not counted.
+type CircuitBreaker struct {
+	Caller
+	// KeyFunc determines the circuit of a request. If nil, the request's host is used.
+	KeyFunc func(req *http.Request) string
+	// ConsecutiveFailures opens the circuit after this number of consecutive failures. If both ConsecutiveFailures and FailureRatio are zero, 5 is used.
+	ConsecutiveFailures int
+	// FailureRatio opens the circuit when the ratio of failed requests reaches this value. Zero disables the check.
+	FailureRatio float64
+	// MinRequests is the minimum number of requests before FailureRatio is considered. Default: 10
+	MinRequests int
+	// Interval is the period after which a closed circuit resets its counts. Zero means the counts are only reset when the circuit closes.
+	Interval time.Duration
+	// Cooldown is the time a circuit stays open before going half-open. Default: 30s
+	Cooldown time.Duration
+	// HalfOpenProbes is the number of requests a half-open circuit lets through. Default: 1
+	HalfOpenProbes int
+	// IsFailure determines whether a request failed. If nil, any error or 5xx response is a failure.
+	IsFailure func(resp *http.Response, err error) bool
+	// OnStateChange is called whenever a circuit changes state. Optional.
+	OnStateChange func(key string, from, to CircuitState)
+	// Metrics records the state of each circuit. Optional.
+	Metrics *CircuitBreakerMetrics
+	// Application is used as the application label in Metrics.
+	Application string
+
+	lock     sync.Mutex
+	circuits map[string]*circuit
+}
+
+var _ Caller = &CircuitBreaker{}
+
+const (
+	defaultConsecutiveFailures = 5
+	defaultMinRequests         = 10
+	defaultCooldown            = 30 * time.Second
+	defaultHalfOpenProbes      = 1
+)
+
+type circuit struct {
+	state               CircuitState
+	generation          uint64
+	requests            int
+	failures            int
+	consecutiveFailures int
+	windowStart         time.Time
+	openedAt            time.Time
+	probes              int
+	probeSuccesses      int
+}
+
+type outcome int
+
+const (
+	outcomeIgnored outcome = iota
+	outcomeSuccess
+	outcomeFailure
+)
+
+// Do sends the request, unless the request's circuit is open.
+func (cb *CircuitBreaker) Do(req *http.Request) (*http.Response, error) {
+	key := cb.key(req)
+	generation, err := cb.before(key)
+	if err != nil {
+		cb.Metrics.reportRejected(cb.Application, key)
+		return nil, err
+	}
+
+	resp, err := cb.Caller.Do(req)
+
+	cb.after(key, generation, cb.outcome(resp, err))
+	return resp, err
+}
+
+// State returns the current state of the circuit for the provided key
+func (cb *CircuitBreaker) State(key string) CircuitState {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+	if c, ok := cb.circuits[key]; ok {
+		return c.state
+	}
+	return CircuitClosed
+}
+
+func (cb *CircuitBreaker) key(req *http.Request) string {
+	if cb.KeyFunc == nil {
+		return req.URL.Host
+	}
+	return cb.KeyFunc(req)
+}
+
+func (cb *CircuitBreaker) outcome(resp *http.Response, err error) outcome {
+	if errors.Is(err, context.Canceled) {
+		return outcomeIgnored
+	}
+	var failed bool
+	if cb.IsFailure != nil {
+		failed = cb.IsFailure(resp, err)
+	} else {
+		failed = err != nil || resp.StatusCode >= http.StatusInternalServerError
+	}
+	if failed {
+		return outcomeFailure
+	}
+	return outcomeSuccess
+}
+
+func (cb *CircuitBreaker) before(key string) (uint64, error) {
+	cb.lock.Lock()
+	c := cb.circuit(key)
+	now := time.Now()
+	var changed bool
+	var from CircuitState
+
+	switch c.state {
+	case CircuitClosed:
+		if cb.Interval > 0 && now.Sub(c.windowStart) >= cb.Interval {
+			c.resetCounts(now)
+		}
+	case CircuitOpen:
+		if elapsed := now.Sub(c.openedAt); elapsed < cb.cooldown() {
+			cb.lock.Unlock()
+			return 0, &CircuitOpenError{Key: key, RetryAfter: cb.cooldown() - elapsed}
+		}
+		from, changed = cb.setState(c, CircuitHalfOpen, now), true
+	}
+
+	if c.state == CircuitHalfOpen {
+		if c.probes >= cb.halfOpenProbes() {
+			cb.lock.Unlock()
+			cb.notify(key, from, CircuitHalfOpen, changed)
+			return 0, &CircuitOpenError{Key: key}
+		}
+		c.probes++
+	}
+	generation := c.generation
+	cb.lock.Unlock()
+
+	cb.notify(key, from, CircuitHalfOpen, changed)
+	return generation, nil
+}
+
+func (cb *CircuitBreaker) after(key string, generation uint64, result outcome) {
+	cb.lock.Lock()
+	c := cb.circuit(key)
+	if c.generation != generation {
+		// the circuit changed state since the request was sent
+		cb.lock.Unlock()
+		return
+	}
+
+	now := time.Now()
+	var changed bool
+	var from, to CircuitState
+
+	switch c.state {
+	case CircuitClosed:
+		if result == outcomeIgnored {
+			break
+		}
+		c.requests++
+		if result == outcomeFailure {
+			c.failures++
+			c.consecutiveFailures++
+		} else {
+			c.consecutiveFailures = 0
+		}
+		if cb.shouldTrip(c) {
+			from, to, changed = cb.setState(c, CircuitOpen, now), CircuitOpen, true
+		}
+	case CircuitHalfOpen:
+		c.probes--
+		switch result {
+		case outcomeFailure:
+			from, to, changed = cb.setState(c, CircuitOpen, now), CircuitOpen, true
+		case outcomeSuccess:
+			c.probeSuccesses++
+			if c.probeSuccesses >= cb.halfOpenProbes() {
+				from, to, changed = cb.setState(c, CircuitClosed, now), CircuitClosed, true
+			}
+		}
+	}
+	cb.lock.Unlock()
+
+	cb.notify(key, from, to, changed)
+}
+
+func (cb *CircuitBreaker) shouldTrip(c *circuit) bool {
+	consecutiveFailures := cb.ConsecutiveFailures
+	if consecutiveFailures == 0 && cb.FailureRatio == 0 {
+		consecutiveFailures = defaultConsecutiveFailures
+	}
+	if consecutiveFailures > 0 && c.consecutiveFailures >= consecutiveFailures {
+		return true
+	}
+	minRequests := cb.MinRequests
+	if minRequests <= 0 {
+		minRequests = defaultMinRequests
+	}
+	return cb.FailureRatio > 0 && c.requests >= minRequests && float64(c.failures)/float64(c.requests) >= cb.FailureRatio
+}
+
+// setState moves the circuit to the new state and returns the previous one. Must be called with the lock held.
+func (cb *CircuitBreaker) setState(c *circuit, state CircuitState, now time.Time) CircuitState {
+	from := c.state
+	c.state = state
+	c.generation++
+	c.resetCounts(now)
+	c.probes, c.probeSuccesses = 0, 0
+	if state == CircuitOpen {
+		c.openedAt = now
+	}
+	return from
+}
+
+func (c *circuit) resetCounts(now time.Time) {
+	c.requests, c.failures, c.consecutiveFailures = 0, 0, 0
+	c.windowStart = now
+}
+
+// circuit returns the circuit for the key, creating it if needed. Must be called with the lock held.
+func (cb *CircuitBreaker) circuit(key string) *circuit {
+	if cb.circuits == nil {
+		cb.circuits = make(map[string]*circuit)
+	}
+	c, ok := cb.circuits[key]
+	if !ok {
+		c = &circuit{windowStart: time.Now()}
+		cb.circuits[key] = c
+	}
+	return c
+}
+
+func (cb *CircuitBreaker) notify(key string, from, to CircuitState, changed bool) {
+	if !changed {
+		return
+	}
+	cb.Metrics.reportStateChange(cb.Application, key, to)
+	if cb.OnStateChange != nil {
+		cb.OnStateChange(key, from, to)
+	}
+}
+
+func (cb *CircuitBreaker) cooldown() time.Duration {
+	if cb.Cooldown <= 0 {
+		return defaultCooldown
+	}
+	return cb.Cooldown
+}
+
+func (cb *CircuitBreaker) halfOpenProbes() int {
+	if cb.HalfOpenProbes <= 0 {
+		return defaultHalfOpenProbes
+	}
+	return cb.HalfOpenProbes
+}
+
+// CircuitBreakerMetrics contains Prometheus metrics recorded by CircuitBreaker.
+type CircuitBreakerMetrics struct {
+	state        *prometheus.GaugeVec   // current state of each circuit
+	stateChanges *prometheus.CounterVec // number of state changes, by new state
+	rejected     *prometheus.CounterVec // number of requests rejected by an open circuit
+}
+
+// NewCircuitBreakerMetrics creates the Prometheus metrics recorded by CircuitBreaker.
+func NewCircuitBreakerMetrics(namespace, subsystem string) *CircuitBreakerMetrics {
+	return &CircuitBreakerMetrics{
+		state: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: prometheus.BuildFQName(namespace, subsystem, "api_circuit_state"),
+			Help: "State of the circuit (0: closed, 1: open, 2: half-open)",
+		}, []string{"application", "key"}),
+		stateChanges: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: prometheus.BuildFQName(namespace, subsystem, "api_circuit_state_changes_total"),
+			Help: "Number of circuit state changes, by new state",
+		}, []string{"application", "key", "state"}),
+		rejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: prometheus.BuildFQName(namespace, subsystem, "api_circuit_rejected_total"),
+			Help: "Number of API calls rejected by an open circuit",
+		}, []string{"application", "key"}),
+	}
+}
+
+var _ prometheus.Collector = &CircuitBreakerMetrics{}
+
+// Describe implements the prometheus.Collector interface so clients can register CircuitBreakerMetrics as a whole
+func (m *CircuitBreakerMetrics) Describe(ch chan<- *prometheus.Desc) {
+	m.state.Describe(ch)
+	m.stateChanges.Describe(ch)
+	m.rejected.Describe(ch)
+}
+
+// Collect implements the prometheus.Collector interface so clients can register CircuitBreakerMetrics as a whole
+func (m *CircuitBreakerMetrics) Collect(ch chan<- prometheus.Metric) {
+	m.state.Collect(ch)
+	m.stateChanges.Collect(ch)
+	m.rejected.Collect(ch)
+}
+
+func (m *CircuitBreakerMetrics) reportStateChange(application, key string, state CircuitState) {
+	if m == nil {
+		return
+	}
+	m.state.WithLabelValues(application, key).Set(float64(state))
+	m.stateChanges.WithLabelValues(application, key, state.String()).Inc()
+}
+
+func (m *CircuitBreakerMetrics) reportRejected(application, key string) {
+	if m == nil {
+		return
+	}
+	m.rejected.WithLabelValues(application, key).Inc()
+}
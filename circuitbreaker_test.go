@@ -0,0 +1,125 @@
+package httpclient_test
+
+import (
+	"context"
+	"errors"
+	"github.com/clambin/httpclient"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker_Do(t *testing.T) {
+	status := http.StatusInternalServerError
+	var calls int
+	var changes []string
+	metrics := httpclient.NewCircuitBreakerMetrics("foo", "")
+	r := prometheus.NewRegistry()
+	r.MustRegister(metrics)
+
+	cb := &httpclient.CircuitBreaker{
+		Caller: httpclient.CallerFunc(func(req *http.Request) (*http.Response, error) {
+			calls++
+			return &http.Response{StatusCode: status, Body: http.NoBody}, nil
+		}),
+		ConsecutiveFailures: 3,
+		Cooldown:            100 * time.Millisecond,
+		OnStateChange: func(key string, from, to httpclient.CircuitState) {
+			changes = append(changes, key+": "+from.String()+" -> "+to.String())
+		},
+		Metrics:     metrics,
+		Application: "foo",
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/foo", nil)
+	for i := 0; i < 3; i++ {
+		_, err := cb.Do(req)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, httpclient.CircuitOpen, cb.State("example.com"))
+
+	// open circuit fails fast
+	_, err := cb.Do(req)
+	require.ErrorIs(t, err, httpclient.ErrCircuitOpen)
+	var openErr *httpclient.CircuitOpenError
+	require.ErrorAs(t, err, &openErr)
+	assert.Equal(t, "example.com", openErr.Key)
+	assert.Equal(t, 3, calls)
+
+	// other hosts are not affected
+	req2, _ := http.NewRequest(http.MethodGet, "http://example.org/foo", nil)
+	_, err = cb.Do(req2)
+	require.NoError(t, err)
+
+	// after cooldown, the failing probe re-opens the circuit
+	time.Sleep(150 * time.Millisecond)
+	_, err = cb.Do(req)
+	require.NoError(t, err)
+	assert.Equal(t, httpclient.CircuitOpen, cb.State("example.com"))
+
+	// after cooldown, a successful probe closes the circuit
+	time.Sleep(150 * time.Millisecond)
+	status = http.StatusOK
+	_, err = cb.Do(req)
+	require.NoError(t, err)
+	assert.Equal(t, httpclient.CircuitClosed, cb.State("example.com"))
+
+	assert.Equal(t, []string{
+		"example.com: closed -> open",
+		"example.com: open -> half-open",
+		"example.com: half-open -> open",
+		"example.com: open -> half-open",
+		"example.com: half-open -> closed",
+	}, changes)
+
+	assert.NoError(t, testutil.GatherAndCompare(r, strings.NewReader(`
+# HELP foo_api_circuit_rejected_total Number of API calls rejected by an open circuit
+# TYPE foo_api_circuit_rejected_total counter
+foo_api_circuit_rejected_total{application="foo",key="example.com"} 1
+# HELP foo_api_circuit_state State of the circuit (0: closed, 1: open, 2: half-open)
+# TYPE foo_api_circuit_state gauge
+foo_api_circuit_state{application="foo",key="example.com"} 0
+`), "foo_api_circuit_rejected_total", "foo_api_circuit_state"))
+}
+
+func TestCircuitBreaker_Do_FailureRatio(t *testing.T) {
+	var calls int
+	cb := &httpclient.CircuitBreaker{
+		Caller: httpclient.CallerFunc(func(req *http.Request) (*http.Response, error) {
+			calls++
+			if calls%2 == 0 {
+				return nil, errors.New("connection refused")
+			}
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+		}),
+		FailureRatio: 0.5,
+		MinRequests:  4,
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/foo", nil)
+	for i := 0; i < 3; i++ {
+		_, _ = cb.Do(req)
+		assert.Equal(t, httpclient.CircuitClosed, cb.State("example.com"))
+	}
+	_, _ = cb.Do(req)
+	assert.Equal(t, httpclient.CircuitOpen, cb.State("example.com"))
+}
+
+func TestCircuitBreaker_Do_Cancelled(t *testing.T) {
+	cb := &httpclient.CircuitBreaker{
+		Caller: httpclient.CallerFunc(func(req *http.Request) (*http.Response, error) {
+			return nil, context.Canceled
+		}),
+		ConsecutiveFailures: 1,
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/foo", nil)
+	_, err := cb.Do(req)
+	require.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, httpclient.CircuitClosed, cb.State("example.com"))
+}
@@ -0,0 +1,208 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ErrInvalidPath indicates that a request path can't be resolved safely against the client's base URL
+var ErrInvalidPath = errors.New("invalid path")
+
+// Client implements the Caller interface for a single upstream. It adds default headers, including a User-Agent, to each request.
+// Use NewRequest to build requests relative to the BaseURL.
+type Client struct {
+	Caller
+	// BaseURL is the URL that request paths are relative to, e.g. https://example.com/api/v1
+	BaseURL string
+	// Application is added to the default User-Agent. Typically, this is the same application name passed to InstrumentedClient.
+	Application string
+	// UserAgent overrides the default User-Agent
+	UserAgent string
+	// Header holds default headers, added to each request that doesn't set them
+	Header http.Header
+	// Query holds default query parameters, added to each request built by NewRequest that doesn't set them
+	Query url.Values
+}
+
+var _ Caller = &Client{}
+
+// Do adds the default headers to the request and sends it
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	clone := req.Clone(req.Context())
+	if clone.Header == nil {
+		clone.Header = make(http.Header)
+	}
+	for name, values := range c.Header {
+		if _, ok := clone.Header[http.CanonicalHeaderKey(name)]; !ok {
+			clone.Header[http.CanonicalHeaderKey(name)] = append([]string(nil), values...)
+		}
+	}
+	if clone.Header.Get("User-Agent") == "" {
+		clone.Header.Set("User-Agent", c.userAgent())
+	}
+	return c.Caller.Do(clone)
+}
+
+func (c *Client) userAgent() string {
+	switch {
+	case c.UserAgent != "":
+		return c.UserAgent
+	case c.Application != "":
+		return c.Application + " (github.com/clambin/httpclient)"
+	default:
+		return "github.com/clambin/httpclient"
+	}
+}
+
+// NewRequest returns a RequestBuilder for a request to path, relative to the client's BaseURL. The path may contain parameters,
+// e.g. "/users/{id}", whose values are set by RequestBuilder.PathParam.
+func (c *Client) NewRequest(ctx context.Context, method, path string) *RequestBuilder {
+	return &RequestBuilder{
+		client: c,
+		ctx:    ctx,
+		method: method,
+		path:   path,
+		params: make(map[string]string),
+		query:  make(url.Values),
+		header: make(http.Header),
+	}
+}
+
+// RequestBuilder builds a request for a Client. Any errors are reported by Build or Do.
+type RequestBuilder struct {
+	client *Client
+	ctx    context.Context
+	method string
+	path   string
+	params map[string]string
+	query  url.Values
+	header http.Header
+	body   io.Reader
+	err    error
+}
+
+// PathParam sets the value of the path parameter {name}. The value is escaped, so it always stays within a single path segment.
+func (b *RequestBuilder) PathParam(name, value string) *RequestBuilder {
+	b.params[name] = value
+	return b
+}
+
+// Query adds values for the query parameter name. These replace any default values of the Client.
+func (b *RequestBuilder) Query(name string, values ...string) *RequestBuilder {
+	b.query[name] = append(b.query[name], values...)
+	return b
+}
+
+// Header sets the header name to value
+func (b *RequestBuilder) Header(name, value string) *RequestBuilder {
+	b.header.Set(name, value)
+	return b
+}
+
+// Body sets the request body and its content type
+func (b *RequestBuilder) Body(body io.Reader, contentType string) *RequestBuilder {
+	b.body = body
+	if contentType != "" {
+		b.header.Set("Content-Type", contentType)
+	}
+	return b
+}
+
+// JSON sets the request body to v, encoded as JSON
+func (b *RequestBuilder) JSON(v any) *RequestBuilder {
+	body, err := json.Marshal(v)
+	if err != nil {
+		b.err = fmt.Errorf("encode: %w", err)
+		return b
+	}
+	return b.Body(bytes.NewReader(body), "application/json")
+}
+
+// Form sets the request body to the URL-encoded form values
+func (b *RequestBuilder) Form(values url.Values) *RequestBuilder {
+	return b.Body(strings.NewReader(values.Encode()), "application/x-www-form-urlencoded")
+}
+
+// Build returns the request
+func (b *RequestBuilder) Build() (*http.Request, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	u, err := b.url()
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(b.ctx, b.method, u.String(), b.body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range b.header {
+		req.Header[name] = values
+	}
+	return req, nil
+}
+
+// Do builds the request and sends it through the Client
+func (b *RequestBuilder) Do() (*http.Response, error) {
+	req, err := b.Build()
+	if err != nil {
+		return nil, err
+	}
+	return b.client.Do(req)
+}
+
+func (b *RequestBuilder) url() (*url.URL, error) {
+	base, err := url.Parse(b.client.BaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("base url: %w", err)
+	}
+	path, err := b.escapedPath()
+	if err != nil {
+		return nil, err
+	}
+
+	u := *base
+	u.RawPath = strings.TrimSuffix(base.EscapedPath(), "/") + path
+	if u.Path, err = url.PathUnescape(u.RawPath); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPath, err)
+	}
+
+	query := base.Query()
+	for name, values := range b.client.Query {
+		query[name] = append([]string(nil), values...)
+	}
+	for name, values := range b.query {
+		query[name] = values
+	}
+	u.RawQuery = query.Encode()
+	return &u, nil
+}
+
+// escapedPath expands the path parameters and checks that the resulting path stays below the base URL
+func (b *RequestBuilder) escapedPath() (string, error) {
+	if strings.ContainsAny(b.path, "?#") || strings.Contains(b.path, "://") {
+		return "", fmt.Errorf("%w: %q", ErrInvalidPath, b.path)
+	}
+	segments := strings.Split(strings.TrimPrefix(b.path, "/"), "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			value, ok := b.params[segment[1:len(segment)-1]]
+			if !ok {
+				return "", fmt.Errorf("%w: missing value for path parameter %s", ErrInvalidPath, segment)
+			}
+			segment = url.PathEscape(value)
+		}
+		if unescaped, err := url.PathUnescape(segment); err != nil || unescaped == "." || unescaped == ".." {
+			return "", fmt.Errorf("%w: %q", ErrInvalidPath, b.path)
+		}
+		segments[i] = segment
+	}
+	return "/" + strings.Join(segments, "/"), nil
+}
@@ -0,0 +1,115 @@
+package httpclient_test
+
+import (
+	"context"
+	"github.com/clambin/httpclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestClient_NewRequest(t *testing.T) {
+	var received *http.Request
+	var body string
+	c := &httpclient.Client{
+		Caller: httpclient.CallerFunc(func(req *http.Request) (*http.Response, error) {
+			received = req
+			if req.Body != nil {
+				b, _ := io.ReadAll(req.Body)
+				body = string(b)
+			}
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+		}),
+		BaseURL:     "https://example.com/api/v1/?version=1",
+		Application: "foo",
+		Header:      http.Header{"Accept": {"application/json"}},
+		Query:       url.Values{"format": {"json"}, "limit": {"10"}},
+	}
+
+	resp, err := c.NewRequest(context.Background(), http.MethodPost, "/users/{id}/items").
+		PathParam("id", "a/b c").
+		Query("limit", "20").
+		Query("tag", "x", "y").
+		Header("X-Request-Id", "123").
+		JSON(map[string]string{"name": "bar"}).
+		Do()
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	assert.Equal(t, http.MethodPost, received.Method)
+	assert.Equal(t, "https://example.com/api/v1/users/a%2Fb%20c/items?format=json&limit=20&tag=x&tag=y&version=1", received.URL.String())
+	assert.Equal(t, "foo (github.com/clambin/httpclient)", received.Header.Get("User-Agent"))
+	assert.Equal(t, "application/json", received.Header.Get("Accept"))
+	assert.Equal(t, "application/json", received.Header.Get("Content-Type"))
+	assert.Equal(t, "123", received.Header.Get("X-Request-Id"))
+	assert.Equal(t, `{"name":"bar"}`, body)
+	assert.NotNil(t, received.GetBody)
+}
+
+func TestClient_NewRequest_Form(t *testing.T) {
+	c := &httpclient.Client{BaseURL: "https://example.com"}
+	req, err := c.NewRequest(context.Background(), http.MethodPost, "login").Form(url.Values{"user": {"foo"}}).Build()
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/login", req.URL.String())
+	assert.Equal(t, "application/x-www-form-urlencoded", req.Header.Get("Content-Type"))
+	body, _ := io.ReadAll(req.Body)
+	assert.Equal(t, "user=foo", string(body))
+}
+
+func TestClient_NewRequest_InvalidPath(t *testing.T) {
+	c := &httpclient.Client{BaseURL: "https://example.com/api"}
+	for _, tc := range []struct {
+		name  string
+		path  string
+		param string
+	}{
+		{name: "traversal", path: "/../admin"},
+		{name: "traversal in parameter", path: "/users/{id}", param: ".."},
+		{name: "missing parameter", path: "/users/{name}"},
+		{name: "absolute url", path: "https://evil.com/"},
+		{name: "query", path: "/users?admin=true"},
+		{name: "invalid escape", path: "/users/%zz"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := c.NewRequest(context.Background(), http.MethodGet, tc.path).PathParam("id", tc.param).Build()
+			assert.ErrorIs(t, err, httpclient.ErrInvalidPath)
+		})
+	}
+
+	c.BaseURL = "::"
+	_, err := c.NewRequest(context.Background(), http.MethodGet, "/").Do()
+	assert.Error(t, err)
+
+	_, err = c.NewRequest(context.Background(), http.MethodGet, "/").JSON(func() {}).Build()
+	assert.ErrorContains(t, err, "encode:")
+}
+
+func TestClient_Do_DefaultHeaders(t *testing.T) {
+	var received *http.Request
+	c := &httpclient.Client{
+		Caller: httpclient.CallerFunc(func(req *http.Request) (*http.Response, error) {
+			received = req
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+		}),
+		UserAgent: "bar/1.0",
+		Header:    http.Header{"X-Tenant": {"foo"}},
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "https://example.com/", nil)
+	req.Header.Set("X-Tenant", "snafu")
+	_, err := c.Do(req)
+	require.NoError(t, err)
+	assert.Equal(t, "bar/1.0", received.Header.Get("User-Agent"))
+	assert.Equal(t, "snafu", received.Header.Get("X-Tenant"))
+	assert.Empty(t, req.Header.Get("User-Agent"))
+
+	// Client works with the JSON helpers
+	_, err = httpclient.GetJSON[string](context.Background(), c, "https://example.com/")
+	require.NoError(t, err)
+	assert.Equal(t, "application/json", received.Header.Get("Accept"))
+	assert.True(t, strings.HasPrefix(received.Header.Get("User-Agent"), "bar"))
+}
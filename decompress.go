@@ -0,0 +1,230 @@
+package httpclient
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// ErrDecompressionLimit indicates that a decompressed response body exceeds the Decompressor's MaxSize or MaxRatio
+var ErrDecompressionLimit = errors.New("decompression limit exceeded")
+
+// DefaultEncodings are the content encodings that Decompressor accepts by default, in order of preference
+var DefaultEncodings = []string{"zstd", "br", "gzip", "deflate"}
+
+const (
+	defaultMaxDecompressedSize = 100 << 20
+	defaultMaxRatio            = 100
+	// minRatioCheckSize is the decompressed size from which MaxRatio is enforced, so small, highly compressible bodies are accepted
+	minRatioCheckSize = 1 << 20
+	// zstdMaxWindowSize is the largest window allowed by the zstd specification
+	zstdMaxWindowSize = (1 << 41) + 7*(1<<38)
+)
+
+// Decompressor implements the Caller interface. It decompresses response bodies encoded with zstd, br, gzip or deflate.
+// It removes the Content-Encoding and Content-Length headers from decompressed responses and sets the response's Uncompressed flag.
+//
+// If the request has no Accept-Encoding header, Decompressor sets it to the supported Encodings. Since the request then carries
+// an explicit Accept-Encoding header, the http.Client's transport no longer decompresses gzip responses itself.
+// Placing a Cacher behind the Decompressor caches the compressed responses.
+//
+// To protect against decompression bombs, reading a decompressed body fails with ErrDecompressionLimit when the body
+// exceeds MaxSize bytes, or when it exceeds 1 MiB and is more than MaxRatio times larger than the compressed body.
+// zstd responses that declare a window larger than MaxSize are rejected before the decoder allocates it.
+type Decompressor struct {
+	Caller
+	// Encodings lists the accepted encodings, in order of preference. Default: DefaultEncodings
+	Encodings []string
+	// MaxSize is the maximum size of a decompressed body. Default: 100 MiB
+	MaxSize int64
+	// MaxRatio is the maximum ratio of the decompressed size to the compressed size. Default: 100
+	MaxRatio int64
+}
+
+var _ Caller = &Decompressor{}
+
+// Do sends the request and decompresses the response body
+func (d *Decompressor) Do(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Accept-Encoding") == "" {
+		encodings := d.Encodings
+		if len(encodings) == 0 {
+			encodings = DefaultEncodings
+		}
+		req = req.Clone(req.Context())
+		if req.Header == nil {
+			req.Header = make(http.Header)
+		}
+		req.Header.Set("Accept-Encoding", strings.Join(encodings, ", "))
+	}
+
+	resp, err := d.Caller.Do(req)
+	if err != nil || resp.Body == nil || resp.Body == http.NoBody {
+		return resp, err
+	}
+	encodings := contentEncodings(resp.Header)
+	if len(encodings) == 0 || !supportedEncodings(encodings) {
+		return resp, nil
+	}
+
+	compressed := &countingReader{r: resp.Body}
+	body := &decompressedBody{compressed: compressed, closers: []io.Closer{resp.Body}, maxSize: d.MaxSize, maxRatio: d.MaxRatio}
+	var r io.Reader = compressed
+	// encodings are listed in the order they were applied, so decode them in reverse order
+	for i := len(encodings) - 1; i >= 0; i-- {
+		if r, err = decoder(encodings[i], r, body); err != nil {
+			_ = body.Close()
+			return nil, fmt.Errorf("decompress %s: %w", encodings[i], err)
+		}
+	}
+	body.r = r
+
+	resp.Body = body
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return resp, nil
+}
+
+// contentEncodings returns the response's content encodings, ignoring "identity"
+func contentEncodings(header http.Header) []string {
+	var encodings []string
+	for _, value := range header.Values("Content-Encoding") {
+		for _, encoding := range strings.Split(value, ",") {
+			encoding = strings.ToLower(strings.TrimSpace(encoding))
+			if encoding != "" && encoding != "identity" {
+				encodings = append(encodings, encoding)
+			}
+		}
+	}
+	return encodings
+}
+
+func supportedEncodings(encodings []string) bool {
+	for _, encoding := range encodings {
+		switch encoding {
+		case "zstd", "br", "gzip", "x-gzip", "deflate":
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func decoder(encoding string, r io.Reader, body *decompressedBody) (io.Reader, error) {
+	switch encoding {
+	case "zstd":
+		// limit the window (and so the decoder's memory) to the maximum decompressed size: the frame header is rejected
+		// before the decoder allocates its window
+		limit := uint64(min(max(effectiveMaxSize(body.maxSize), zstd.MinWindowSize), zstdMaxWindowSize))
+		decoder, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxWindow(limit), zstd.WithDecoderMaxMemory(limit))
+		if err != nil {
+			return nil, err
+		}
+		rc := decoder.IOReadCloser()
+		body.closers = append(body.closers, rc)
+		return rc, nil
+	case "br":
+		return brotli.NewReader(r), nil
+	case "gzip", "x-gzip":
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		body.closers = append(body.closers, gz)
+		return gz, nil
+	default:
+		return deflateReader(r, body)
+	}
+}
+
+// deflateReader decodes "deflate" content. RFC 9110 defines this as zlib-wrapped data, but some servers send raw deflate data.
+func deflateReader(r io.Reader, body *decompressedBody) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	var rc io.ReadCloser
+	if header, err := br.Peek(2); err == nil && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		if rc, err = zlib.NewReader(br); err != nil {
+			return nil, err
+		}
+	} else {
+		rc = flate.NewReader(br)
+	}
+	body.closers = append(body.closers, rc)
+	return rc, nil
+}
+
+// countingReader counts the bytes read from r
+type countingReader struct {
+	r     io.Reader
+	count int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.count += int64(n)
+	return n, err
+}
+
+// decompressedBody reads the decompressed response body, enforcing the decompression limits
+type decompressedBody struct {
+	r          io.Reader
+	compressed *countingReader
+	closers    []io.Closer
+	maxSize    int64
+	maxRatio   int64
+	count      int64
+	exceeded   bool
+}
+
+// Read returns no data beyond the decompression limits. Once a limit is exceeded, Read stops decompressing and fails.
+func (b *decompressedBody) Read(p []byte) (int, error) {
+	if b.exceeded {
+		return 0, ErrDecompressionLimit
+	}
+	n, err := b.r.Read(p)
+	if errors.Is(err, zstd.ErrWindowSizeExceeded) || errors.Is(err, zstd.ErrDecoderSizeExceeded) {
+		b.exceeded = true
+		return n, fmt.Errorf("%w: %w", ErrDecompressionLimit, err)
+	}
+	b.count += int64(n)
+	if limit := b.limit(); b.count > limit {
+		n -= int(b.count - limit)
+		b.count = limit
+		b.exceeded = true
+		return n, ErrDecompressionLimit
+	}
+	return n, err
+}
+
+// limit returns the maximum decompressed size, given the number of compressed bytes read so far
+func (b *decompressedBody) limit() int64 {
+	maxRatio := b.maxRatio
+	if maxRatio <= 0 {
+		maxRatio = defaultMaxRatio
+	}
+	return min(effectiveMaxSize(b.maxSize), max(minRatioCheckSize, b.compressed.count*maxRatio))
+}
+
+func effectiveMaxSize(maxSize int64) int64 {
+	if maxSize <= 0 {
+		return defaultMaxDecompressedSize
+	}
+	return maxSize
+}
+
+func (b *decompressedBody) Close() error {
+	var err error
+	// close the decoders before the underlying body
+	for i := len(b.closers) - 1; i >= 0; i-- {
+		err = errors.Join(err, b.closers[i].Close())
+	}
+	return err
+}
@@ -0,0 +1,235 @@
+package httpclient_test
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"github.com/andybalholm/brotli"
+	"github.com/clambin/cache"
+	"github.com/clambin/httpclient"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func compress(t *testing.T, encoding string, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case "zstd":
+		w, _ = zstd.NewWriter(&buf)
+	case "br":
+		w = brotli.NewWriter(&buf)
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "raw-deflate":
+		w, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+	default:
+		return data
+	}
+	_, err := w.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func compressedServer(t *testing.T, data []byte) *httptest.Server {
+	t.Helper()
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		encoding := req.URL.Query().Get("encoding")
+		body := data
+		for _, e := range strings.Split(encoding, ",") {
+			body = compress(t, e, body)
+		}
+		if encoding == "raw-deflate" {
+			encoding = "deflate"
+		}
+		w.Header().Set("Content-Encoding", strings.Replace(encoding, ",", ", ", -1))
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.Header().Set("X-Accept-Encoding", req.Header.Get("Accept-Encoding"))
+		_, _ = w.Write(body)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestDecompressor_Do(t *testing.T) {
+	data := []byte(strings.Repeat("hello world ", 100))
+	s := compressedServer(t, data)
+	c := &httpclient.Decompressor{Caller: &httpclient.BaseClient{}}
+
+	for _, encoding := range []string{"zstd", "br", "gzip", "deflate", "raw-deflate", "identity", "gzip,br"} {
+		t.Run(encoding, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, s.URL+"?encoding="+encoding, nil)
+			resp, err := c.Do(req)
+			require.NoError(t, err)
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.NoError(t, resp.Body.Close())
+
+			assert.Equal(t, data, body)
+			assert.Equal(t, "zstd, br, gzip, deflate", resp.Header.Get("X-Accept-Encoding"))
+			assert.Empty(t, req.Header.Get("Accept-Encoding"))
+			if encoding != "identity" {
+				assert.Empty(t, resp.Header.Get("Content-Encoding"))
+				assert.Empty(t, resp.Header.Get("Content-Length"))
+				assert.Equal(t, int64(-1), resp.ContentLength)
+				assert.True(t, resp.Uncompressed)
+			}
+		})
+	}
+}
+
+func TestDecompressor_Do_NilHeader(t *testing.T) {
+	data := []byte("hello")
+	s := compressedServer(t, data)
+	c := &httpclient.Decompressor{Caller: &httpclient.BaseClient{}}
+
+	req, _ := http.NewRequest(http.MethodGet, s.URL+"?encoding=gzip", nil)
+	req.Header = nil
+	resp, err := c.Do(req)
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, data, body)
+	assert.Equal(t, "zstd, br, gzip, deflate", resp.Header.Get("X-Accept-Encoding"))
+}
+
+func TestDecompressor_Do_Unsupported(t *testing.T) {
+	data := []byte("hello")
+	s := compressedServer(t, data)
+	c := &httpclient.Decompressor{Caller: &httpclient.BaseClient{}, Encodings: []string{"gzip"}}
+
+	req, _ := http.NewRequest(http.MethodGet, s.URL+"?encoding=compress", nil)
+	req.Header.Set("Accept-Encoding", "compress")
+	resp, err := c.Do(req)
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	assert.Equal(t, data, body)
+	assert.Equal(t, "compress", resp.Header.Get("Content-Encoding"))
+	assert.Equal(t, "compress", resp.Header.Get("X-Accept-Encoding"))
+
+	// invalid compressed data
+	c.Caller = httpclient.CallerFunc(func(*http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{"Content-Encoding": {"gzip"}}, Body: io.NopCloser(strings.NewReader("hello"))}, nil
+	})
+	_, err = c.Do(req)
+	assert.ErrorContains(t, err, "decompress gzip")
+}
+
+func TestDecompressor_Do_Limits(t *testing.T) {
+	data := make([]byte, 10<<20)
+	s := compressedServer(t, data)
+
+	for _, tc := range []struct {
+		name       string
+		decompress httpclient.Decompressor
+		wantErr    bool
+		wantLen    int
+	}{
+		{name: "ratio", decompress: httpclient.Decompressor{}, wantErr: true},
+		{name: "size", decompress: httpclient.Decompressor{MaxSize: 1 << 20, MaxRatio: 1 << 20}, wantErr: true, wantLen: 1 << 20},
+		{name: "size not aligned with reads", decompress: httpclient.Decompressor{MaxSize: 1<<20 - 1000, MaxRatio: 1 << 20}, wantErr: true, wantLen: 1<<20 - 1000},
+		{name: "allowed", decompress: httpclient.Decompressor{MaxRatio: 1 << 20}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := tc.decompress
+			c.Caller = &httpclient.BaseClient{}
+			req, _ := http.NewRequest(http.MethodGet, s.URL+"?encoding=gzip", nil)
+			resp, err := c.Do(req)
+			require.NoError(t, err)
+			body, err := io.ReadAll(resp.Body)
+			if tc.wantErr {
+				assert.ErrorIs(t, err, httpclient.ErrDecompressionLimit)
+				// no data is returned beyond the limit, and reading stops
+				assert.LessOrEqual(t, len(body), 1<<20)
+				if tc.wantLen > 0 {
+					assert.Len(t, body, tc.wantLen)
+				}
+				n, err := resp.Body.Read(make([]byte, 1024))
+				assert.Zero(t, n)
+				assert.ErrorIs(t, err, httpclient.ErrDecompressionLimit)
+				_ = resp.Body.Close()
+				return
+			}
+			_ = resp.Body.Close()
+			assert.NoError(t, err)
+			assert.Len(t, body, len(data))
+		})
+	}
+}
+
+func TestDecompressor_Do_ZstdWindow(t *testing.T) {
+	// a zstd frame holding a single raw block with "hello", but declaring a 512 MiB window
+	frame := []byte{0x28, 0xb5, 0x2f, 0xfd, 0x00, 19 << 3, 5<<3 | 1, 0x00, 0x00, 'h', 'e', 'l', 'l', 'o'}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Encoding", "zstd")
+		_, _ = w.Write(frame)
+	}))
+	t.Cleanup(s.Close)
+
+	tests := []struct {
+		name    string
+		maxSize int64
+		wantErr bool
+	}{
+		{name: "window too large", maxSize: 2 << 20, wantErr: true},
+		{name: "window allowed", maxSize: 1 << 30, wantErr: false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c := httpclient.Decompressor{Caller: &httpclient.BaseClient{}, MaxSize: tc.maxSize}
+			req, _ := http.NewRequest(http.MethodGet, s.URL, nil)
+			resp, err := c.Do(req)
+			require.NoError(t, err)
+			body, err := io.ReadAll(resp.Body)
+			_ = resp.Body.Close()
+			if tc.wantErr {
+				assert.ErrorIs(t, err, httpclient.ErrDecompressionLimit)
+				assert.ErrorIs(t, err, zstd.ErrWindowSizeExceeded)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "hello", string(body))
+		})
+	}
+}
+
+func TestDecompressor_Do_Cached(t *testing.T) {
+	data := []byte(strings.Repeat("hello world ", 100))
+	s := compressedServer(t, data)
+	store := cache.New[string, []byte](time.Minute, 0)
+	c := httpclient.Chain(&httpclient.BaseClient{},
+		func(next httpclient.Caller) httpclient.Caller { return &httpclient.Decompressor{Caller: next} },
+		httpclient.CachingMiddleware(nil, store),
+	)
+
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest(http.MethodGet, s.URL+"?encoding=br", nil)
+		resp, err := c.Do(req)
+		require.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		assert.Equal(t, data, body)
+	}
+
+	// the cache holds the compressed response
+	cached, ok := store.Get(s.URL + "?encoding=br")
+	require.True(t, ok)
+	assert.Contains(t, string(cached), "Content-Encoding: br")
+	assert.Less(t, len(cached), len(data))
+}
@@ -3,15 +3,69 @@ Package httpclient provides a standard way of writing API clients. It's meant to
 Currently, it supports generating Prometheus metrics when performing API calls, and caching API responses.
 
 InstrumentedClient generates Prometheus metrics when performing API calls. Currently, it records request latency and errors.
+To use OpenTelemetry metrics instead, set Options.Metrics to an OTelMetrics recorder.
+
+TracingClient creates an OpenTelemetry span for each API call and propagates the trace context to the server.
+
+LoggingClient logs the outcome of each API call, using log/slog.
+
+Retrier retries failed API calls, using exponential backoff with jitter.
+
+CircuitBreaker fails fast when an upstream keeps failing, until it has recovered.
+
+RateLimiter limits the rate of API calls, globally, per host or per endpoint.
+
+ConcurrencyLimiter limits the number of in-flight API calls per upstream.
+
+Hedger sends a second request when the first one takes too long, to reduce tail latency.
+
+TimeoutPolicy applies a timeout to each API call, based on its endpoint.
+
+Authenticator adds credentials (bearer token, basic authentication, API key or static headers) to API calls for the configured hosts.
+
+OAuth2Client adds OAuth2 access tokens, obtained through the client-credentials or refresh-token grant.
+
+SigningClient signs API calls, using AWS Signature Version 4 or HMAC-SHA256. It must be the last Caller before the BaseClient.
+
+GetJSON, PostJSON and DoJSON perform an API call and decode its JSON response into a typed value.
+
+StatusChecker turns responses with an unexpected status code into an *HTTPError, which matches sentinel errors like ErrNotFound.
+
+Client sends API calls to a single upstream. It resolves request paths against a base URL and adds default headers and query parameters.
+
+Pages and PagesJSON iterate over the pages of a paginated API, following Link headers, cursors or offsets.
+
+EventSource receives Server-Sent Events, reconnecting when the stream ends. Cacher and InstrumentedClient detect event streams and skip caching and time-to-last-byte measurement for them.
+
+Decompressor decompresses zstd, brotli, gzip and deflate encoded responses, with limits to protect against decompression bombs.
 
 Cacher caches responses to HTTP requests, based on the provided CacheTableEntry slice. If the slice is empty, all responses will be cached.
 
-Note: NewCacher will create a Caller that also generates Prometheus metrics by chaining the request to an InstrumentedClient.
-To avoid this, create a Cacher object directly:
+New creates a Caller from a set of options. Each option adds a layer, so only the layers you need are included:
+
+	c, err := httpclient.New(
+		httpclient.WithApplication("test"),
+		httpclient.WithMetrics(httpclient.Options{PrometheusMetrics: metrics}),
+		httpclient.WithCache(cache.New[string, []byte](cacheExpiry, cacheCleanup)),
+		httpclient.WithCacheTable(cacheEntries),
+		httpclient.WithRetries(3),
+	)
+
+Note: NewCacher is deprecated. It creates a Caller that also generates Prometheus metrics by chaining the request to an InstrumentedClient.
+
+Alternatively, create each layer directly:
 
 	c := &httpclient.Cacher{
 		Caller: &httpclient.BaseClient{},
 		Table: httpclient.CacheTable{Table: cacheEntries},
 		Cache: cache.New[string, []byte](cacheExpiry, cacheCleanup),
+	}
+
+To build the stack in a different order, use Chain. The first Middleware is the outermost layer:
+
+	c := httpclient.Chain(&httpclient.BaseClient{},
+		httpclient.InstrumentationMiddleware("test", httpclient.Options{PrometheusMetrics: metrics}),
+		httpclient.CachingMiddleware(cacheEntries, cache.New[string, []byte](cacheExpiry, cacheCleanup)),
+	)
 */
 package httpclient
@@ -2,6 +2,7 @@ package httpclient_test
 
 import (
 	"fmt"
+	"github.com/clambin/cache"
 	"github.com/clambin/httpclient"
 	"github.com/prometheus/client_golang/prometheus"
 	"io"
@@ -26,6 +27,28 @@ func ExampleInstrumentedClient() {
 	}
 }
 
+func ExampleNew() {
+	metrics := httpclient.NewMetrics("foo", "bar")
+	prometheus.DefaultRegisterer.MustRegister(metrics)
+
+	c, err := httpclient.New(
+		httpclient.WithApplication("test"),
+		httpclient.WithMetrics(httpclient.Options{PrometheusMetrics: metrics}),
+		httpclient.WithCache(cache.New[string, []byte](time.Minute, time.Hour)),
+		httpclient.WithRetries(3),
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	if resp, err := c.Do(req); err == nil {
+		body, _ := io.ReadAll(resp.Body)
+		fmt.Print(string(body))
+		_ = resp.Body.Close()
+	}
+}
+
 func ExampleCacher() {
 	metrics := httpclient.NewMetrics("foo", "bar")
 	prometheus.DefaultRegisterer.MustRegister(metrics)
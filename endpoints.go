@@ -0,0 +1,152 @@
+package httpclient
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// OtherEndpoint is the endpoint label value used by EndpointNames once MaxEndpoints distinct endpoints have been recorded.
+const OtherEndpoint = "other"
+
+// EndpointNamer determines the value of the endpoint label that InstrumentedClient uses when recording metrics for a request.
+type EndpointNamer interface {
+	EndpointName(req *http.Request) string
+}
+
+// EndpointNames implements EndpointNamer. It normalises the request's URL Path, to limit the number of distinct endpoint label values
+// (and therefore the cardinality of the recorded metrics).
+//
+// The endpoint name is determined as follows:
+//   - if the path matches one of the Routes, the route template is used;
+//   - otherwise, if the path matches one of the Rules, the rule's Name is used;
+//   - otherwise, if ReplaceIDs is set, any numeric or UUID path segments are replaced by a placeholder;
+//   - otherwise, the path is used as-is.
+//
+// If MaxEndpoints is set, any new endpoint names beyond that number are reported as OtherEndpoint.
+//
+// EndpointNames will panic if a Rule contains an invalid regular expression.
+type EndpointNames struct {
+	// Routes contains route templates, e.g. "/users/{id}". A segment between braces matches any single (non-empty) path segment.
+	Routes []string
+	// Rules contains regular expressions that rewrite matching paths to a fixed name.
+	Rules []EndpointRule
+	// ReplaceIDs replaces numeric path segments by "{id}" and UUID path segments by "{uuid}".
+	ReplaceIDs bool
+	// MaxEndpoints caps the number of distinct endpoint names. Zero means no limit.
+	MaxEndpoints int
+	compiled     bool
+	seen         map[string]struct{}
+	lock         sync.Mutex
+}
+
+var _ EndpointNamer = &EndpointNames{}
+
+// EndpointRule rewrites any URL Path matching the regular expression Pattern to Name.
+type EndpointRule struct {
+	Pattern        string
+	Name           string
+	compiledRegExp *regexp.Regexp
+}
+
+// EndpointName returns the endpoint name for the request
+func (e *EndpointNames) EndpointName(req *http.Request) string {
+	e.compileIfNeeded()
+	return e.limit(e.name(req.URL.Path))
+}
+
+func (e *EndpointNames) name(path string) string {
+	for _, route := range e.Routes {
+		if matchesRoute(route, path) {
+			return route
+		}
+	}
+	for _, rule := range e.Rules {
+		if rule.compiledRegExp.MatchString(path) {
+			return rule.Name
+		}
+	}
+	if e.ReplaceIDs {
+		return replaceIDs(path)
+	}
+	return path
+}
+
+func (e *EndpointNames) limit(name string) string {
+	if e.MaxEndpoints <= 0 {
+		return name
+	}
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if _, found := e.seen[name]; found {
+		return name
+	}
+	if len(e.seen) >= e.MaxEndpoints {
+		return OtherEndpoint
+	}
+	if e.seen == nil {
+		e.seen = make(map[string]struct{})
+	}
+	e.seen[name] = struct{}{}
+	return name
+}
+
+func (e *EndpointNames) compileIfNeeded() {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if e.compiled {
+		return
+	}
+
+	for index := range e.Rules {
+		var err error
+		e.Rules[index].compiledRegExp, err = regexp.Compile(e.Rules[index].Pattern)
+		if err != nil {
+			panic(fmt.Errorf("endpointNames: invalid regexp '%s': %w", e.Rules[index].Pattern, err))
+		}
+	}
+	e.compiled = true
+}
+
+func matchesRoute(route, path string) bool {
+	routeSegments := strings.Split(route, "/")
+	pathSegments := strings.Split(path, "/")
+	if len(routeSegments) != len(pathSegments) {
+		return false
+	}
+	for index, segment := range routeSegments {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			if pathSegments[index] == "" {
+				return false
+			}
+			continue
+		}
+		if segment != pathSegments[index] {
+			return false
+		}
+	}
+	return true
+}
+
+var (
+	numericSegment = regexp.MustCompile(`^\d+$`)
+	uuidSegment    = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+)
+
+func replaceIDs(path string) string {
+	segments := strings.Split(path, "/")
+	for index, segment := range segments {
+		switch {
+		case numericSegment.MatchString(segment):
+			segments[index] = "{id}"
+		case uuidSegment.MatchString(segment):
+			segments[index] = "{uuid}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,56 @@
+package httpclient
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestEndpointNames_EndpointName(t *testing.T) {
+	names := EndpointNames{
+		Routes: []string{"/users/{id}/orders/{order}"},
+		Rules: []EndpointRule{
+			{Pattern: `^/static/`, Name: "static"},
+		},
+		ReplaceIDs: true,
+	}
+
+	for _, tc := range []struct {
+		path string
+		want string
+	}{
+		{path: "/users/123/orders/456", want: "/users/{id}/orders/{order}"},
+		{path: "/users/123/orders/", want: "/users/{id}/orders/"},
+		{path: "/static/css/main.css", want: "static"},
+		{path: "/users/123", want: "/users/{id}"},
+		{path: "/items/0b9f2a2e-3f4c-4d5e-8f6a-7b8c9d0e1f2a/details", want: "/items/{uuid}/details"},
+		{path: "/foo", want: "/foo"},
+	} {
+		t.Run(tc.path, func(t *testing.T) {
+			assert.Equal(t, tc.want, names.EndpointName(&http.Request{URL: &url.URL{Path: tc.path}}))
+		})
+	}
+}
+
+func TestEndpointNames_MaxEndpoints(t *testing.T) {
+	names := EndpointNames{MaxEndpoints: 2}
+
+	for _, tc := range []struct {
+		path string
+		want string
+	}{
+		{path: "/foo", want: "/foo"},
+		{path: "/bar", want: "/bar"},
+		{path: "/snafu", want: OtherEndpoint},
+		{path: "/foo", want: "/foo"},
+	} {
+		assert.Equal(t, tc.want, names.EndpointName(&http.Request{URL: &url.URL{Path: tc.path}}))
+	}
+}
+
+func TestEndpointNames_Invalid_Input(t *testing.T) {
+	names := EndpointNames{Rules: []EndpointRule{{Pattern: `/foo/[\d+`}}}
+
+	assert.Panics(t, func() { names.EndpointName(&http.Request{URL: &url.URL{Path: "/foo"}}) })
+}
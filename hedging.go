@@ -0,0 +1,290 @@
+package httpclient
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Hedger implements the Caller interface. It reduces tail latency by sending the same request more than once: if the first attempt
+// hasn't returned a response after a delay, Hedger sends another one, up to MaxHedges extra requests. The first successful response
+// (i.e. no error and a status code below 500) is returned. The other requests are cancelled and their response bodies drained.
+//
+// The delay is either fixed (Delay), or derived from the observed latency of the endpoint (Percentile). In the latter case, Delay is used
+// until MinSamples latencies have been observed.
+//
+// By default, only GET and HEAD requests without a body are hedged.
+type Hedger struct {
+	Caller
+	// Delay is the time to wait before sending the next request. Default: 100ms
+	Delay time.Duration
+	// Percentile (between 0 and 1) derives the delay from the observed latency of the endpoint, e.g. 0.95 sends a hedge once
+	// the request takes longer than 95% of the previous requests. Zero uses a fixed Delay.
+	Percentile float64
+	// MinSamples is the number of observed latencies needed to derive the delay from Percentile. Default: 20
+	MinSamples int
+	// MaxHedges is the maximum number of extra requests. Default: 1
+	MaxHedges int
+	// ShouldHedge determines if a request can be hedged. If nil, GET and HEAD requests without a body are hedged.
+	ShouldHedge func(req *http.Request) bool
+	// EndpointNamer determines the endpoint, used to track latencies and as the endpoint label in Metrics. If nil, the request's URL Path is used.
+	EndpointNamer EndpointNamer
+	// Metrics records how often hedges are sent and how often they win. Optional.
+	Metrics *HedgeMetrics
+	// Application is used as the application label in Metrics.
+	Application string
+
+	lock      sync.Mutex
+	latencies map[string]*latencyWindow
+}
+
+var _ Caller = &Hedger{}
+
+const (
+	defaultHedgeDelay = 100 * time.Millisecond
+	defaultMinSamples = 20
+	latencyWindowSize = 100
+)
+
+type hedgeResult struct {
+	index   int
+	resp    *http.Response
+	err     error
+	latency time.Duration
+}
+
+// Do sends the request, hedging it if it takes too long.
+func (h *Hedger) Do(req *http.Request) (*http.Response, error) {
+	if !h.shouldHedge(req) {
+		return h.Caller.Do(req)
+	}
+
+	endpoint := h.endpoint(req)
+	delay := h.delay(endpoint)
+	maxAttempts := h.maxHedges() + 1
+	results := make(chan hedgeResult, maxAttempts)
+	cancels := make([]context.CancelFunc, 0, maxAttempts)
+
+	launch := func() {
+		ctx, cancel := context.WithCancel(req.Context())
+		cancels = append(cancels, cancel)
+		attemptReq := req.Clone(ctx)
+		index := len(cancels) - 1
+		go func() {
+			start := time.Now()
+			resp, err := h.Caller.Do(attemptReq)
+			results <- hedgeResult{index: index, resp: resp, err: err, latency: time.Since(start)}
+		}()
+	}
+
+	launch()
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	var last *hedgeResult
+	inFlight := 1
+	for {
+		select {
+		case <-timer.C:
+			if len(cancels) < maxAttempts {
+				launch()
+				inFlight++
+				h.Metrics.reportHedge(h.Application, endpoint)
+				timer.Reset(delay)
+			}
+		case result := <-results:
+			inFlight--
+			if result.err == nil && result.resp.StatusCode < http.StatusInternalServerError {
+				h.observe(endpoint, result.latency)
+				if result.index > 0 {
+					h.Metrics.reportWin(h.Application, endpoint)
+				}
+				discard(last, cancels)
+				h.cancelOthers(result.index, cancels, results, inFlight)
+				return withCancel(result.resp, cancels[result.index]), nil
+			}
+			discard(last, cancels)
+			last = &result
+			if len(cancels) < maxAttempts {
+				// don't wait for the delay: send the next request straight away
+				launch()
+				inFlight++
+				h.Metrics.reportHedge(h.Application, endpoint)
+				timer.Reset(delay)
+				continue
+			}
+			if inFlight == 0 {
+				if last.err != nil {
+					cancels[last.index]()
+					return last.resp, last.err
+				}
+				return withCancel(last.resp, cancels[last.index]), nil
+			}
+		}
+	}
+}
+
+// cancelOthers cancels all requests, except the winner, and drains their responses in the background
+func (h *Hedger) cancelOthers(winner int, cancels []context.CancelFunc, results <-chan hedgeResult, inFlight int) {
+	for index, cancel := range cancels {
+		if index != winner {
+			cancel()
+		}
+	}
+	go func() {
+		for ; inFlight > 0; inFlight-- {
+			result := <-results
+			drainAndClose(result.resp)
+		}
+	}()
+}
+
+// discard drains the body of a failed result and cancels its request
+func discard(result *hedgeResult, cancels []context.CancelFunc) {
+	if result != nil {
+		drainAndClose(result.resp)
+		cancels[result.index]()
+	}
+}
+
+// withCancel cancels the request's context once the response body has been read, or is closed.
+func withCancel(resp *http.Response, cancel context.CancelFunc) *http.Response {
+	resp.Body = &instrumentedBody{ReadCloser: resp.Body, onDone: func(error) { cancel() }}
+	return resp
+}
+
+func (h *Hedger) shouldHedge(req *http.Request) bool {
+	if h.ShouldHedge != nil {
+		return h.ShouldHedge(req)
+	}
+	return (req.Method == "" || req.Method == http.MethodGet || req.Method == http.MethodHead) &&
+		(req.Body == nil || req.Body == http.NoBody)
+}
+
+func (h *Hedger) endpoint(req *http.Request) string {
+	if h.EndpointNamer == nil {
+		return req.URL.Path
+	}
+	return h.EndpointNamer.EndpointName(req)
+}
+
+func (h *Hedger) maxHedges() int {
+	if h.MaxHedges <= 0 {
+		return 1
+	}
+	return h.MaxHedges
+}
+
+func (h *Hedger) delay(endpoint string) time.Duration {
+	delay := h.Delay
+	if delay <= 0 {
+		delay = defaultHedgeDelay
+	}
+	if h.Percentile <= 0 {
+		return delay
+	}
+
+	minSamples := h.MinSamples
+	if minSamples <= 0 {
+		minSamples = defaultMinSamples
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if window, ok := h.latencies[endpoint]; ok && window.count() >= minSamples {
+		delay = window.percentile(h.Percentile)
+	}
+	return delay
+}
+
+func (h *Hedger) observe(endpoint string, latency time.Duration) {
+	if h.Percentile <= 0 {
+		return
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.latencies == nil {
+		h.latencies = make(map[string]*latencyWindow)
+	}
+	window, ok := h.latencies[endpoint]
+	if !ok {
+		window = &latencyWindow{}
+		h.latencies[endpoint] = window
+	}
+	window.add(latency)
+}
+
+// latencyWindow holds the most recent latencies of an endpoint
+type latencyWindow struct {
+	samples []time.Duration
+	next    int
+}
+
+func (w *latencyWindow) add(latency time.Duration) {
+	if len(w.samples) < latencyWindowSize {
+		w.samples = append(w.samples, latency)
+		return
+	}
+	w.samples[w.next] = latency
+	w.next = (w.next + 1) % latencyWindowSize
+}
+
+func (w *latencyWindow) count() int {
+	return len(w.samples)
+}
+
+func (w *latencyWindow) percentile(p float64) time.Duration {
+	sorted := slices.Clone(w.samples)
+	slices.Sort(sorted)
+	index := int(p * float64(len(sorted)-1))
+	return sorted[min(max(index, 0), len(sorted)-1)]
+}
+
+// HedgeMetrics contains Prometheus metrics recorded by Hedger.
+type HedgeMetrics struct {
+	hedges *prometheus.CounterVec // number of hedged requests sent
+	wins   *prometheus.CounterVec // number of hedged requests that returned the response
+}
+
+// NewHedgeMetrics creates the Prometheus metrics recorded by Hedger.
+func NewHedgeMetrics(namespace, subsystem string) *HedgeMetrics {
+	return &HedgeMetrics{
+		hedges: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: prometheus.BuildFQName(namespace, subsystem, "api_hedges_total"),
+			Help: "Number of hedged API calls sent",
+		}, []string{"application", "endpoint"}),
+		wins: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: prometheus.BuildFQName(namespace, subsystem, "api_hedge_wins_total"),
+			Help: "Number of hedged API calls that returned the response",
+		}, []string{"application", "endpoint"}),
+	}
+}
+
+var _ prometheus.Collector = &HedgeMetrics{}
+
+// Describe implements the prometheus.Collector interface so clients can register HedgeMetrics as a whole
+func (m *HedgeMetrics) Describe(ch chan<- *prometheus.Desc) {
+	m.hedges.Describe(ch)
+	m.wins.Describe(ch)
+}
+
+// Collect implements the prometheus.Collector interface so clients can register HedgeMetrics as a whole
+func (m *HedgeMetrics) Collect(ch chan<- prometheus.Metric) {
+	m.hedges.Collect(ch)
+	m.wins.Collect(ch)
+}
+
+func (m *HedgeMetrics) reportHedge(application, endpoint string) {
+	if m != nil {
+		m.hedges.WithLabelValues(application, endpoint).Inc()
+	}
+}
+
+func (m *HedgeMetrics) reportWin(application, endpoint string) {
+	if m != nil {
+		m.wins.WithLabelValues(application, endpoint).Inc()
+	}
+}
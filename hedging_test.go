@@ -0,0 +1,150 @@
+package httpclient_test
+
+import (
+	"github.com/clambin/httpclient"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHedger_Do(t *testing.T) {
+	var calls atomic.Int32
+	cancelled := make(chan struct{}, 1)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if calls.Add(1) == 1 {
+			select {
+			case <-req.Context().Done():
+				cancelled <- struct{}{}
+				return
+			case <-time.After(5 * time.Second):
+			}
+		}
+		_, _ = w.Write([]byte("hello"))
+	}))
+	defer s.Close()
+
+	r := prometheus.NewRegistry()
+	metrics := httpclient.NewHedgeMetrics("foo", "")
+	r.MustRegister(metrics)
+	c := &httpclient.Hedger{
+		Caller:      &httpclient.BaseClient{},
+		Delay:       50 * time.Millisecond,
+		Metrics:     metrics,
+		Application: "foo",
+	}
+
+	start := time.Now()
+	req, _ := http.NewRequest(http.MethodGet, s.URL+"/foo", nil)
+	resp, err := c.Do(req)
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, "hello", string(body))
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, int32(2), calls.Load())
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("first request was not cancelled")
+	}
+
+	assert.Equal(t, float64(1), metricValue(t, r, "foo_api_hedges_total"))
+	assert.Equal(t, float64(1), metricValue(t, r, "foo_api_hedge_wins_total"))
+}
+
+func TestHedger_Do_NoHedge(t *testing.T) {
+	var calls atomic.Int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		calls.Add(1)
+		time.Sleep(100 * time.Millisecond)
+		_, _ = w.Write([]byte("hello"))
+	}))
+	defer s.Close()
+
+	c := &httpclient.Hedger{Caller: &httpclient.BaseClient{}, Delay: time.Second}
+
+	req, _ := http.NewRequest(http.MethodGet, s.URL+"/foo", nil)
+	resp, err := c.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, int32(1), calls.Load())
+
+	// POST requests are not hedged
+	c.Delay = time.Millisecond
+	req, _ = http.NewRequest(http.MethodPost, s.URL+"/foo", nil)
+	resp, err = c.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestHedger_Do_Failure(t *testing.T) {
+	var calls atomic.Int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("hello"))
+	}))
+	defer s.Close()
+
+	c := &httpclient.Hedger{Caller: &httpclient.BaseClient{}, Delay: time.Hour, MaxHedges: 1}
+
+	req, _ := http.NewRequest(http.MethodGet, s.URL+"/foo", nil)
+	resp, err := c.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// all attempts fail: last response is returned
+	c.Caller = httpclient.CallerFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusBadGateway, Body: http.NoBody}, nil
+	})
+	resp, err = c.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+}
+
+func TestHedger_Do_Percentile(t *testing.T) {
+	var slow atomic.Bool
+	var calls atomic.Int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		calls.Add(1)
+		if slow.CompareAndSwap(true, false) {
+			select {
+			case <-req.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+			return
+		}
+		_, _ = w.Write([]byte("hello"))
+	}))
+	defer s.Close()
+
+	c := &httpclient.Hedger{Caller: &httpclient.BaseClient{}, Delay: time.Hour, Percentile: 0.9, MinSamples: 5}
+
+	req, _ := http.NewRequest(http.MethodGet, s.URL+"/foo", nil)
+	for i := 0; i < 5; i++ {
+		resp, err := c.Do(req)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+	}
+
+	slow.Store(true)
+	start := time.Now()
+	resp, err := c.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, int32(7), calls.Load())
+}
@@ -0,0 +1,148 @@
+package httpclient
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+)
+
+// Sentinel errors for common status codes. An *HTTPError matches the sentinel for its status code, so clients can use errors.Is:
+//
+//	if errors.Is(err, httpclient.ErrNotFound) { ... }
+var (
+	ErrBadRequest   = errors.New("bad request")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrRateLimited  = errors.New("rate limited")
+	ErrServerError  = errors.New("server error")
+)
+
+// MaxErrorBodySize is the maximum size of the response body excerpt in an HTTPError
+const MaxErrorBodySize = 4 << 10
+
+// HTTPError is returned when the server returns an unexpected status code
+type HTTPError struct {
+	StatusCode int
+	Status     string
+	Method     string
+	URL        string
+	// Header holds the response headers
+	Header http.Header
+	// Body holds the first MaxErrorBodySize bytes of the response body
+	Body []byte
+	// Problem holds the problem details, if the response has an application/problem+json body (RFC 9457)
+	Problem *ProblemDetails
+}
+
+// ProblemDetails holds the details of an application/problem+json response, as defined in RFC 9457
+type ProblemDetails struct {
+	Type     string `json:"type,omitempty"`
+	Title    string `json:"title,omitempty"`
+	Status   int    `json:"status,omitempty"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	// Extensions holds any other members of the problem details
+	Extensions map[string]any `json:"-"`
+}
+
+// NewHTTPError creates an HTTPError for the request's response. It reads an excerpt of the response body, but leaves draining and
+// closing the body to the caller.
+func NewHTTPError(req *http.Request, resp *http.Response) *HTTPError {
+	err := HTTPError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Method:     req.Method,
+		URL:        req.URL.Redacted(),
+		Header:     resp.Header,
+	}
+	if resp.Body != nil {
+		err.Body, _ = io.ReadAll(io.LimitReader(resp.Body, MaxErrorBodySize))
+	}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType == "application/problem+json" {
+		err.Problem = parseProblem(err.Body)
+	}
+	return &err
+}
+
+func parseProblem(body []byte) *ProblemDetails {
+	var problem ProblemDetails
+	if json.Unmarshal(body, &problem) != nil {
+		return nil
+	}
+	var members map[string]any
+	_ = json.Unmarshal(body, &members)
+	for _, member := range []string{"type", "title", "status", "detail", "instance"} {
+		delete(members, member)
+	}
+	if len(members) > 0 {
+		problem.Extensions = members
+	}
+	return &problem
+}
+
+// Error implements the error interface
+func (e *HTTPError) Error() string {
+	msg := e.Method + " " + e.URL + ": " + e.Status
+	if e.Problem != nil {
+		if e.Problem.Title != "" {
+			msg += ": " + e.Problem.Title
+		}
+		if e.Problem.Detail != "" {
+			msg += ": " + e.Problem.Detail
+		}
+	}
+	return msg
+}
+
+// Is reports whether the error matches the sentinel error for its status code
+func (e *HTTPError) Is(target error) bool {
+	switch target {
+	case ErrBadRequest:
+		return e.StatusCode == http.StatusBadRequest
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrServerError:
+		return e.StatusCode >= 500 && e.StatusCode <= 599
+	}
+	return false
+}
+
+// StatusChecker implements the Caller interface. It turns responses with an unexpected status code into an *HTTPError.
+// In that case, it drains and closes the response body and returns a nil response.
+type StatusChecker struct {
+	Caller
+	// Accept returns true if the status code is expected. Default: any 2xx status code
+	Accept func(statusCode int) bool
+}
+
+var _ Caller = &StatusChecker{}
+
+// Do sends the request and checks the response's status code
+func (s *StatusChecker) Do(req *http.Request) (*http.Response, error) {
+	resp, err := s.Caller.Do(req)
+	if err != nil {
+		return resp, err
+	}
+	accept := s.Accept
+	if accept == nil {
+		accept = func(statusCode int) bool { return statusCode >= 200 && statusCode <= 299 }
+	}
+	if accept(resp.StatusCode) {
+		return resp, nil
+	}
+	httpErr := NewHTTPError(req, resp)
+	drainAndClose(resp)
+	return nil, httpErr
+}
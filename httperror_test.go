@@ -0,0 +1,118 @@
+package httpclient_test
+
+import (
+	"errors"
+	"github.com/clambin/httpclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestStatusChecker_Do(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/ok":
+			w.WriteHeader(http.StatusOK)
+		case "/problem":
+			w.Header().Set("Content-Type", "application/problem+json; charset=utf-8")
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"type":"https://example.com/probs/out-of-credit","title":"You do not have enough credit.","status":403,"detail":"Your current balance is 30, but that costs 50.","balance":30}`))
+		case "/large":
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(strings.Repeat("a", 2*httpclient.MaxErrorBodySize)))
+		case "/limited":
+			w.Header().Set("Retry-After", "10")
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			http.Error(w, "invalid endpoint", http.StatusNotFound)
+		}
+	}))
+	defer s.Close()
+
+	c := &httpclient.StatusChecker{Caller: &httpclient.BaseClient{}}
+
+	req, _ := http.NewRequest(http.MethodGet, s.URL+"/ok", nil)
+	resp, err := c.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	req, _ = http.NewRequest(http.MethodGet, s.URL+"/foo", nil)
+	resp, err = c.Do(req)
+	require.Error(t, err)
+	assert.Nil(t, resp)
+	assert.ErrorIs(t, err, httpclient.ErrNotFound)
+	assert.NotErrorIs(t, err, httpclient.ErrServerError)
+	var httpErr *httpclient.HTTPError
+	require.ErrorAs(t, err, &httpErr)
+	assert.Equal(t, "invalid endpoint\n", string(httpErr.Body))
+	assert.Equal(t, "GET "+s.URL+"/foo: 404 Not Found", err.Error())
+
+	req, _ = http.NewRequest(http.MethodGet, s.URL+"/problem", nil)
+	_, err = c.Do(req)
+	assert.ErrorIs(t, err, httpclient.ErrForbidden)
+	require.ErrorAs(t, err, &httpErr)
+	require.NotNil(t, httpErr.Problem)
+	assert.Equal(t, httpclient.ProblemDetails{
+		Type:       "https://example.com/probs/out-of-credit",
+		Title:      "You do not have enough credit.",
+		Status:     http.StatusForbidden,
+		Detail:     "Your current balance is 30, but that costs 50.",
+		Extensions: map[string]any{"balance": float64(30)},
+	}, *httpErr.Problem)
+	assert.Equal(t, "GET "+s.URL+"/problem: 403 Forbidden: You do not have enough credit.: Your current balance is 30, but that costs 50.", err.Error())
+
+	req, _ = http.NewRequest(http.MethodGet, s.URL+"/large", nil)
+	_, err = c.Do(req)
+	assert.ErrorIs(t, err, httpclient.ErrServerError)
+	require.ErrorAs(t, err, &httpErr)
+	assert.Len(t, httpErr.Body, httpclient.MaxErrorBodySize)
+
+	req, _ = http.NewRequest(http.MethodGet, s.URL+"/limited", nil)
+	_, err = c.Do(req)
+	assert.ErrorIs(t, err, httpclient.ErrRateLimited)
+	require.ErrorAs(t, err, &httpErr)
+	assert.Equal(t, "10", httpErr.Header.Get("Retry-After"))
+}
+
+func TestStatusChecker_Do_Accept(t *testing.T) {
+	c := &httpclient.StatusChecker{
+		Caller: httpclient.CallerFunc(func(*http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusNotModified, Status: "304 Not Modified", Body: http.NoBody}, nil
+		}),
+		Accept: func(statusCode int) bool { return statusCode < 400 },
+	}
+	req, _ := http.NewRequest(http.MethodGet, "http://localhost", nil)
+	resp, err := c.Do(req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+
+	c.Caller = httpclient.CallerFunc(func(*http.Request) (*http.Response, error) {
+		return nil, errors.New("connection refused")
+	})
+	_, err = c.Do(req)
+	assert.ErrorContains(t, err, "connection refused")
+}
+
+func TestHTTPError_Is(t *testing.T) {
+	for _, tc := range []struct {
+		statusCode int
+		sentinel   error
+	}{
+		{http.StatusBadRequest, httpclient.ErrBadRequest},
+		{http.StatusUnauthorized, httpclient.ErrUnauthorized},
+		{http.StatusForbidden, httpclient.ErrForbidden},
+		{http.StatusNotFound, httpclient.ErrNotFound},
+		{http.StatusConflict, httpclient.ErrConflict},
+		{http.StatusTooManyRequests, httpclient.ErrRateLimited},
+		{http.StatusBadGateway, httpclient.ErrServerError},
+	} {
+		t.Run(http.StatusText(tc.statusCode), func(t *testing.T) {
+			err := error(&httpclient.HTTPError{StatusCode: tc.statusCode})
+			assert.ErrorIs(t, err, tc.sentinel)
+			assert.NotErrorIs(t, err, errors.New("other"))
+		})
+	}
+}
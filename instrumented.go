@@ -1,7 +1,11 @@
 package httpclient
 
 import (
+	"errors"
+	"io"
 	"net/http"
+	"sync"
+	"time"
 )
 
 // InstrumentedClient implements the Caller interface. If provided by Options, it will collect performance metrics of the API calls
@@ -16,20 +20,99 @@ var _ Caller = &InstrumentedClient{}
 
 // Options contains options to alter InstrumentedClient behaviour
 type Options struct {
-	PrometheusMetrics *Metrics // Prometheus metric to record API performance metrics
+	PrometheusMetrics *Metrics        // Prometheus metric to record API performance metrics
+	Metrics           MetricsRecorder // records API performance metrics. If set, it is used instead of PrometheusMetrics
+	EndpointNamer     EndpointNamer   // determines the endpoint label of the metrics. If nil, the request's URL Path is used
+}
+
+func (o Options) recorder() MetricsRecorder {
+	if o.Metrics != nil {
+		return o.Metrics
+	}
+	if o.PrometheusMetrics != nil {
+		return o.PrometheusMetrics
+	}
+	return nil
 }
 
 // Do sends the request and records performance metrics of the call.
-// Currently, it records the request's duration (i.e. latency) and error rate.
+// Currently, it records the request's duration (i.e. latency), the time until the response body has been read (i.e. time to last byte)
+// and error rate. Errors encountered while reading the response body are also reported as errors.
+// For Server-Sent Events streams (see IsEventStream), only the time until the response headers are received is recorded.
 func (c *InstrumentedClient) Do(req *http.Request) (resp *http.Response, err error) {
-	endpoint := req.URL.Path
-	timer := c.Options.PrometheusMetrics.makeLatencyTimer(c.Application, endpoint, req.Method)
+	return instrumentedDo(&c.BaseClient, c.Application, c.Options, req)
+}
+
+func instrumentedDo(next Caller, application string, options Options, req *http.Request) (resp *http.Response, err error) {
+	recorder := options.recorder()
+	if recorder == nil {
+		return next.Do(req)
+	}
+
+	call := callInfo(application, options, req)
+	start := time.Now()
+
+	resp, err = next.Do(req)
+
+	if err == nil {
+		call.StatusCode = resp.StatusCode
+	}
+	recorder.ReportRequest(req.Context(), call, time.Since(start), err)
+
+	if err == nil && !IsEventStream(resp.Header) {
+		resp.Body = &instrumentedBody{
+			ReadCloser: resp.Body,
+			onDone: func(bodyErr error) {
+				recorder.ReportTransfer(req.Context(), call, time.Since(start), bodyErr)
+			},
+		}
+	}
+	return
+}
 
-	resp, err = c.BaseClient.Do(req)
+func callInfo(application string, options Options, req *http.Request) CallInfo {
+	host, port := hostAndPort(req)
+	return CallInfo{
+		Application:   application,
+		Endpoint:      options.endpoint(req),
+		Method:        req.Method,
+		ServerAddress: host,
+		ServerPort:    port,
+	}
+}
 
-	if timer != nil {
-		timer.ObserveDuration()
+func (o Options) endpoint(req *http.Request) string {
+	if o.EndpointNamer == nil {
+		return req.URL.Path
+	}
+	return o.EndpointNamer.EndpointName(req)
+}
+
+// instrumentedBody wraps a response body and calls onDone once the body has been read completely (or failed), or is closed.
+type instrumentedBody struct {
+	io.ReadCloser
+	onDone func(err error)
+	once   sync.Once
+}
+
+func (b *instrumentedBody) Read(p []byte) (n int, err error) {
+	n, err = b.ReadCloser.Read(p)
+	if err != nil {
+		bodyErr := err
+		if errors.Is(err, io.EOF) {
+			bodyErr = nil
+		}
+		b.done(bodyErr)
 	}
-	c.Options.PrometheusMetrics.reportErrors(err, c.Application, endpoint, req.Method)
 	return
 }
+
+func (b *instrumentedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.done(nil)
+	return err
+}
+
+func (b *instrumentedBody) done(err error) {
+	b.once.Do(func() { b.onDone(err) })
+}
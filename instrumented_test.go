@@ -1,6 +1,7 @@
 package httpclient_test
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/clambin/httpclient"
@@ -8,6 +9,7 @@ import (
 	pcg "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -46,6 +48,108 @@ func TestClient_Do(t *testing.T) {
 	}, getErrorMetrics(t, r, "foo_bar_"))
 }
 
+func TestClient_Do_Transfer(t *testing.T) {
+	r := prometheus.NewRegistry()
+	metrics := httpclient.NewMetrics("foo", "bar")
+	r.MustRegister(metrics)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/truncated" {
+			w.Header().Set("Content-Length", "1024")
+			_, _ = w.Write([]byte("short"))
+			return
+		}
+		handler(w, req)
+	}))
+	defer s.Close()
+
+	c := &httpclient.InstrumentedClient{
+		Options:     httpclient.Options{PrometheusMetrics: metrics},
+		Application: "foo",
+	}
+
+	_, err := doCall(c, s.URL+"/foo")
+	require.NoError(t, err)
+
+	req, _ := http.NewRequest(http.MethodGet, s.URL+"/truncated", nil)
+	resp, err := c.Do(req)
+	require.NoError(t, err)
+	_, err = io.ReadAll(resp.Body)
+	require.Error(t, err)
+	_ = resp.Body.Close()
+
+	assert.Equal(t, map[string]uint64{
+		"/foo":       1,
+		"/truncated": 1,
+	}, getTransferCounters(t, r, "foo_bar_"))
+
+	assert.Equal(t, map[string]float64{
+		"/foo":       0,
+		"/truncated": 1,
+	}, getErrorMetrics(t, r, "foo_bar_"))
+}
+
+func TestClient_Do_EndpointNamer(t *testing.T) {
+	r := prometheus.NewRegistry()
+	metrics := httpclient.NewMetrics("foo", "bar")
+	r.MustRegister(metrics)
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	c := &httpclient.InstrumentedClient{
+		Options: httpclient.Options{
+			PrometheusMetrics: metrics,
+			EndpointNamer:     &httpclient.EndpointNames{ReplaceIDs: true, MaxEndpoints: 2},
+		},
+		Application: "foo",
+	}
+
+	for _, path := range []string{"/foo", "/users/1", "/users/2", "/bar"} {
+		_, _ = doCall(c, s.URL+path)
+	}
+
+	assert.Equal(t, map[string]uint64{
+		"/foo":                   1,
+		"/users/{id}":            2,
+		httpclient.OtherEndpoint: 1,
+	}, getLatencyCounters(t, r, "foo_bar_"))
+}
+
+func TestClient_Do_MetricLabels(t *testing.T) {
+	r := prometheus.NewRegistry()
+	metrics := httpclient.NewMetrics("foo", "bar", "tenant")
+	r.MustRegister(metrics)
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	c := &httpclient.InstrumentedClient{
+		Options:     httpclient.Options{PrometheusMetrics: metrics},
+		Application: "foo",
+	}
+
+	for _, tenant := range []string{"a", "b", "a"} {
+		req, _ := http.NewRequestWithContext(httpclient.WithMetricLabel(context.Background(), "tenant", tenant), http.MethodGet, s.URL+"/foo", nil)
+		resp, err := c.Do(req)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+	}
+
+	counters := make(map[string]uint64)
+	m, err := r.Gather()
+	require.NoError(t, err)
+	for _, entry := range m {
+		if *entry.Name == "foo_bar_api_latency" {
+			for _, metric := range entry.Metric {
+				for _, label := range metric.Label {
+					if label.GetName() == "tenant" {
+						counters[label.GetValue()] = metric.Summary.GetSampleCount()
+					}
+				}
+			}
+		}
+	}
+	assert.Equal(t, map[string]uint64{"a": 2, "b": 1}, counters)
+}
+
 type testStruct struct {
 	Name string `json:"name"`
 	Age  int    `json:"age"`
@@ -97,12 +201,22 @@ func getErrorMetrics(t *testing.T, g prometheus.Gatherer, prefix string) map[str
 
 func getLatencyCounters(t *testing.T, g prometheus.Gatherer, prefix string) map[string]uint64 {
 	t.Helper()
+	return getSummaryCounters(t, g, prefix+"api_latency")
+}
+
+func getTransferCounters(t *testing.T, g prometheus.Gatherer, prefix string) map[string]uint64 {
+	t.Helper()
+	return getSummaryCounters(t, g, prefix+"api_transfer_latency")
+}
+
+func getSummaryCounters(t *testing.T, g prometheus.Gatherer, name string) map[string]uint64 {
+	t.Helper()
 
 	counters := make(map[string]uint64)
 	m, err := g.Gather()
 	require.NoError(t, err)
 	for _, entry := range m {
-		if *entry.Name == prefix+"api_latency" {
+		if *entry.Name == name {
 			require.Equal(t, pcg.MetricType_SUMMARY, *entry.Type)
 			for _, metric := range entry.Metric {
 				counters[*metric.Label[1].Value] = *metric.Summary.SampleCount
@@ -0,0 +1,102 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// DefaultMaxJSONBodySize is the default maximum size of a response body decoded by GetJSON, PostJSON, DoJSON and PagesJSON.
+// Use WithMaxBodySize to override it for a call.
+const DefaultMaxJSONBodySize = 10 << 20
+
+// ErrBodyTooLarge indicates that the response body exceeds the maximum body size
+var ErrBodyTooLarge = errors.New("response body too large")
+
+// JSONOption configures a call to GetJSON, PostJSON, DoJSON, Pages or PagesJSON
+type JSONOption func(*jsonOptions)
+
+type jsonOptions struct {
+	maxBodySize int64
+}
+
+// WithMaxBodySize sets the maximum size of the response body. Default: DefaultMaxJSONBodySize
+func WithMaxBodySize(size int64) JSONOption {
+	return func(o *jsonOptions) {
+		o.maxBodySize = size
+	}
+}
+
+// readBodyLimited reads the response body, failing with ErrBodyTooLarge if it exceeds the maximum body size
+func readBodyLimited(resp *http.Response, opts []JSONOption) ([]byte, error) {
+	options := jsonOptions{maxBodySize: DefaultMaxJSONBodySize}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, options.maxBodySize+1))
+	if err != nil {
+		return nil, fmt.Errorf("read: %w", err)
+	}
+	if int64(len(body)) > options.maxBodySize {
+		return nil, ErrBodyTooLarge
+	}
+	return body, nil
+}
+
+// GetJSON performs a GET request for the provided URL and decodes the JSON response into a T
+func GetJSON[T any](ctx context.Context, c Caller, url string, opts ...JSONOption) (T, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		var t T
+		return t, err
+	}
+	return DoJSON[T](c, req, opts...)
+}
+
+// PostJSON sends body, encoded as JSON, to the provided URL and decodes the JSON response into a Resp
+func PostJSON[Req, Resp any](ctx context.Context, c Caller, url string, body Req, opts ...JSONOption) (Resp, error) {
+	var resp Resp
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return resp, fmt.Errorf("encode: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return resp, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return DoJSON[Resp](c, req, opts...)
+}
+
+// DoJSON sends the request and decodes the JSON response into a T. If the server returns a non-2xx status code, DoJSON returns an *HTTPError.
+// If the response has no content, DoJSON returns the zero value of T. DoJSON always drains and closes the response body.
+func DoJSON[T any](c Caller, req *http.Request, opts ...JSONOption) (T, error) {
+	var t T
+	if req.Header.Get("Accept") == "" {
+		req.Header.Set("Accept", "application/json")
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return t, err
+	}
+	defer drainAndClose(resp)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return t, NewHTTPError(req, resp)
+	}
+	body, err := readBodyLimited(resp, opts)
+	if err != nil {
+		return t, err
+	}
+	if resp.StatusCode == http.StatusNoContent || len(body) == 0 {
+		return t, nil
+	}
+	if err = json.Unmarshal(body, &t); err != nil {
+		return t, fmt.Errorf("decode: %w", err)
+	}
+	return t, nil
+}
@@ -0,0 +1,84 @@
+package httpclient_test
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/clambin/httpclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGetJSON(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	c := &httpclient.BaseClient{}
+	resp, err := httpclient.GetJSON[testStruct](context.Background(), c, s.URL+"/foo")
+	require.NoError(t, err)
+	assert.Equal(t, testStruct{Name: "bar", Age: 42}, resp)
+
+	_, err = httpclient.GetJSON[testStruct](context.Background(), c, s.URL+"/bar")
+	var httpErr *httpclient.HTTPError
+	require.ErrorAs(t, err, &httpErr)
+	assert.Equal(t, http.StatusNotFound, httpErr.StatusCode)
+	assert.Equal(t, "GET "+s.URL+"/bar: 404 Not Found", err.Error())
+}
+
+func TestPostJSON(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Content-Type") != "application/json" || req.Header.Get("Accept") != "application/json" {
+			http.Error(w, "invalid headers", http.StatusBadRequest)
+			return
+		}
+		var body testStruct
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if body.Name == "" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		body.Age++
+		_ = json.NewEncoder(w).Encode(body)
+	}))
+	defer s.Close()
+
+	c := &httpclient.BaseClient{}
+	resp, err := httpclient.PostJSON[testStruct, testStruct](context.Background(), c, s.URL, testStruct{Name: "foo", Age: 41})
+	require.NoError(t, err)
+	assert.Equal(t, testStruct{Name: "foo", Age: 42}, resp)
+
+	resp, err = httpclient.PostJSON[testStruct, testStruct](context.Background(), c, s.URL, testStruct{})
+	require.NoError(t, err)
+	assert.Zero(t, resp)
+}
+
+func TestDoJSON_Errors(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/large":
+			_, _ = w.Write([]byte(`"` + strings.Repeat("a", 100) + `"`))
+		default:
+			_, _ = w.Write([]byte(`{`))
+		}
+	}))
+	defer s.Close()
+
+	c := &httpclient.BaseClient{}
+	_, err := httpclient.GetJSON[testStruct](context.Background(), c, s.URL+"/invalid")
+	assert.ErrorContains(t, err, "decode:")
+
+	_, err = httpclient.GetJSON[string](context.Background(), c, s.URL+"/large", httpclient.WithMaxBodySize(64))
+	assert.ErrorIs(t, err, httpclient.ErrBodyTooLarge)
+	resp, err := httpclient.GetJSON[string](context.Background(), c, s.URL+"/large")
+	require.NoError(t, err)
+	assert.Len(t, resp, 100)
+
+	_, err = httpclient.GetJSON[string](context.Background(), c, "::")
+	assert.Error(t, err)
+}
@@ -0,0 +1,229 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// LoggingClient implements the Caller interface. It emits one log record per call, containing the request's method and URL,
+// the response's status and size, the duration of the call, whether the response was served from cache and any error.
+//
+// When placed in front of a Cacher, the record includes the cache outcome ("hit" or "miss").
+type LoggingClient struct {
+	Caller
+	// Logger receives the log records. If nil, slog.Default() is used.
+	Logger *slog.Logger
+	// RedactedQueryParams lists the query parameters whose values are replaced by "REDACTED" in the logged URL.
+	// Passwords in the URL's user info are always redacted.
+	RedactedQueryParams []string
+	// SampleRate is the fraction of successful (i.e. 2xx and 3xx) calls to log. Failed calls, including 4xx and 5xx responses,
+	// are always logged. Zero (or one) logs all calls.
+	SampleRate float64
+	// Level determines the level of the log record. If nil, DefaultLogLevel is used.
+	Level func(resp *http.Response, err error) slog.Level
+	// Debug adds the request and response headers and the (truncated) bodies to the log record.
+	// The request body is only logged if the request's GetBody is set. The body of an event stream (see IsEventStream) is not logged.
+	Debug bool
+	// MaxBodySize is the number of bytes of the response body that are logged in Debug mode. Default: 1024.
+	MaxBodySize int
+	// SensitiveHeaders lists the headers whose values are masked in Debug mode. If nil, DefaultSensitiveHeaders is used.
+	SensitiveHeaders []string
+}
+
+var _ Caller = &LoggingClient{}
+
+// DefaultSensitiveHeaders are the headers that LoggingClient masks by default
+var DefaultSensitiveHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+
+const defaultMaxLoggedBodySize = 1024
+
+// DefaultLogLevel logs failed calls at Error level, 5xx responses at Warn level, 4xx responses at Info level and all other responses at Debug level.
+func DefaultLogLevel(resp *http.Response, err error) slog.Level {
+	switch {
+	case err != nil:
+		return slog.LevelError
+	case resp.StatusCode >= http.StatusInternalServerError:
+		return slog.LevelWarn
+	case resp.StatusCode >= http.StatusBadRequest:
+		return slog.LevelInfo
+	default:
+		return slog.LevelDebug
+	}
+}
+
+// Do sends the request and logs the outcome.
+func (c *LoggingClient) Do(req *http.Request) (resp *http.Response, err error) {
+	outcome := &cacheOutcome{}
+	req = req.WithContext(context.WithValue(req.Context(), cacheOutcomeKey{}, outcome))
+
+	start := time.Now()
+	resp, err = c.Caller.Do(req)
+	duration := time.Since(start)
+
+	if err == nil && resp.StatusCode < http.StatusBadRequest && !c.sampled() {
+		return resp, err
+	}
+
+	level := c.level(resp, err)
+	logger := c.logger()
+	if !logger.Enabled(req.Context(), level) {
+		return resp, err
+	}
+
+	attrs := []slog.Attr{
+		slog.String("method", req.Method),
+		slog.String("url", c.redactURL(req.URL)),
+		slog.Duration("duration", duration),
+	}
+	if cache, ok := outcome.get(); ok {
+		attrs = append(attrs, slog.String("cache", cache))
+	}
+	if err != nil {
+		attrs = append(attrs, slog.Any("err", err))
+	} else {
+		attrs = append(attrs, slog.Int("status", resp.StatusCode))
+		if resp.ContentLength >= 0 {
+			attrs = append(attrs, slog.Int64("bytes", resp.ContentLength))
+		}
+	}
+	if c.Debug {
+		attrs = append(attrs, slog.Any("request_headers", c.maskHeaders(req.Header)))
+		if req.GetBody != nil {
+			if body, bodyErr := req.GetBody(); bodyErr == nil {
+				var buf []byte
+				buf, body = c.peekBody(body)
+				_ = body.Close()
+				attrs = append(attrs, slog.String("request_body", string(buf)))
+			}
+		}
+		if resp != nil {
+			attrs = append(attrs, slog.Any("response_headers", c.maskHeaders(resp.Header)))
+			// reading from an event stream would block until enough events arrive
+			if !IsEventStream(resp.Header) {
+				var body []byte
+				body, resp.Body = c.peekBody(resp.Body)
+				attrs = append(attrs, slog.String("response_body", string(body)))
+			}
+		}
+	}
+
+	logger.LogAttrs(req.Context(), level, "http call", attrs...)
+	return resp, err
+}
+
+func (c *LoggingClient) logger() *slog.Logger {
+	if c.Logger == nil {
+		return slog.Default()
+	}
+	return c.Logger
+}
+
+func (c *LoggingClient) level(resp *http.Response, err error) slog.Level {
+	if c.Level == nil {
+		return DefaultLogLevel(resp, err)
+	}
+	return c.Level(resp, err)
+}
+
+func (c *LoggingClient) sampled() bool {
+	if c.SampleRate <= 0 || c.SampleRate >= 1 {
+		return true
+	}
+	return rand.Float64() < c.SampleRate
+}
+
+func (c *LoggingClient) redactURL(u *url.URL) string {
+	if len(c.RedactedQueryParams) == 0 {
+		return u.Redacted()
+	}
+	redacted := *u
+	query := redacted.Query()
+	for _, param := range c.RedactedQueryParams {
+		if query.Has(param) {
+			query.Set(param, "REDACTED")
+		}
+	}
+	redacted.RawQuery = query.Encode()
+	return redacted.Redacted()
+}
+
+func (c *LoggingClient) maskHeaders(header http.Header) http.Header {
+	sensitive := c.SensitiveHeaders
+	if sensitive == nil {
+		sensitive = DefaultSensitiveHeaders
+	}
+	masked := header.Clone()
+	for _, name := range sensitive {
+		if masked.Get(name) != "" {
+			masked.Set(name, "REDACTED")
+		}
+	}
+	return masked
+}
+
+// peekBody reads up to MaxBodySize bytes from body. It returns these bytes and a ReadCloser that still returns the complete body.
+func (c *LoggingClient) peekBody(body io.ReadCloser) ([]byte, io.ReadCloser) {
+	maxSize := c.MaxBodySize
+	if maxSize <= 0 {
+		maxSize = defaultMaxLoggedBodySize
+	}
+	buf := make([]byte, maxSize)
+	n, err := io.ReadFull(body, buf)
+	buf = buf[:n]
+	restored := &peekedBody{Reader: io.MultiReader(bytes.NewReader(buf), body), Closer: body}
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		restored.Reader = io.MultiReader(bytes.NewReader(buf), &errReader{err: err})
+	}
+	return buf, restored
+}
+
+type peekedBody struct {
+	io.Reader
+	io.Closer
+}
+
+type errReader struct {
+	err error
+}
+
+func (r *errReader) Read(_ []byte) (int, error) {
+	return 0, r.err
+}
+
+type cacheOutcomeKey struct{}
+
+// cacheOutcome records whether Cacher served the response from cache. Concurrent layers (e.g. Hedger) may report
+// several outcomes for the same call, so only the first one is kept.
+type cacheOutcome struct {
+	lock sync.Mutex
+	set  bool
+	hit  bool
+}
+
+func (o *cacheOutcome) record(hit bool) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	if !o.set {
+		o.set, o.hit = true, hit
+	}
+}
+
+// get returns the recorded outcome ("hit" or "miss"), if any
+func (o *cacheOutcome) get() (string, bool) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	if !o.set {
+		return "", false
+	}
+	if o.hit {
+		return "hit", true
+	}
+	return "miss", true
+}
@@ -0,0 +1,221 @@
+package httpclient_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/clambin/cache"
+	"github.com/clambin/httpclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoggingClient_Do(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	var output bytes.Buffer
+	c := &httpclient.LoggingClient{
+		Caller: &httpclient.Cacher{
+			Caller: &httpclient.BaseClient{},
+			Cache:  cache.New[string, []byte](time.Minute, 0),
+		},
+		Logger:              slog.New(slog.NewJSONHandler(&output, &slog.HandlerOptions{Level: slog.LevelDebug})),
+		RedactedQueryParams: []string{"key"},
+	}
+
+	_, err := doCall(c, s.URL+"/foo?key=secret")
+	require.NoError(t, err)
+	_, err = doCall(c, s.URL+"/foo?key=secret")
+	require.NoError(t, err)
+	_, err = doCall(c, "http://localhost:0/foo")
+	require.Error(t, err)
+
+	records := logRecords(t, &output)
+	require.Len(t, records, 3)
+
+	assert.Equal(t, "DEBUG", records[0]["level"])
+	assert.Equal(t, "http call", records[0]["msg"])
+	assert.Equal(t, http.MethodGet, records[0]["method"])
+	assert.Equal(t, s.URL+"/foo?key=REDACTED", records[0]["url"])
+	assert.Equal(t, float64(http.StatusOK), records[0]["status"])
+	assert.Equal(t, "miss", records[0]["cache"])
+	assert.Contains(t, records[0], "duration")
+	assert.Equal(t, "hit", records[1]["cache"])
+
+	assert.Equal(t, "ERROR", records[2]["level"])
+	assert.Contains(t, records[2], "err")
+	assert.Equal(t, "miss", records[2]["cache"])
+}
+
+func TestLoggingClient_Do_Debug(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	var output bytes.Buffer
+	c := &httpclient.LoggingClient{
+		Caller:      &httpclient.BaseClient{},
+		Logger:      slog.New(slog.NewJSONHandler(&output, &slog.HandlerOptions{Level: slog.LevelDebug})),
+		Debug:       true,
+		MaxBodySize: 10,
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, s.URL+"/foo", strings.NewReader("hello world"))
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := c.Do(req)
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, `{"name":"bar","age":42}`+"\n", string(body))
+
+	records := logRecords(t, &output)
+	require.Len(t, records, 1)
+	assert.Equal(t, map[string]any{"Authorization": []any{"REDACTED"}}, records[0]["request_headers"])
+	assert.Equal(t, "hello worl", records[0]["request_body"])
+	assert.Equal(t, `{"name":"b`, records[0]["response_body"])
+	assert.NotContains(t, output.String(), "secret")
+}
+
+func TestLoggingClient_Do_Debug_EventStream(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte("data: foo\n\n"))
+		w.(http.Flusher).Flush()
+		<-req.Context().Done()
+	}))
+	defer s.Close()
+
+	var output bytes.Buffer
+	c := &httpclient.LoggingClient{
+		Caller: &httpclient.BaseClient{},
+		Logger: slog.New(slog.NewJSONHandler(&output, &slog.HandlerOptions{Level: slog.LevelDebug})),
+		Debug:  true,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+	// Do returns without waiting for the stream to fill the logged body
+	resp, err := c.Do(req)
+	require.NoError(t, err)
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "data: foo\n", line)
+	cancel()
+	_ = resp.Body.Close()
+
+	records := logRecords(t, &output)
+	require.Len(t, records, 1)
+	assert.NotContains(t, records[0], "response_body")
+	assert.Contains(t, records[0], "response_headers")
+}
+
+func TestLoggingClient_Do_Level(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	var output bytes.Buffer
+	c := &httpclient.LoggingClient{
+		Caller: &httpclient.BaseClient{},
+		Logger: slog.New(slog.NewJSONHandler(&output, &slog.HandlerOptions{Level: slog.LevelInfo})),
+	}
+
+	_, err := doCall(c, s.URL+"/foo")
+	require.NoError(t, err)
+	_, err = doCall(c, s.URL+"/bar")
+	require.Error(t, err)
+
+	records := logRecords(t, &output)
+	require.Len(t, records, 1)
+	assert.Equal(t, "INFO", records[0]["level"])
+	assert.Equal(t, float64(http.StatusNotFound), records[0]["status"])
+}
+
+func TestLoggingClient_Do_Sampling(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/error" {
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		handler(w, req)
+	}))
+	defer s.Close()
+
+	var output bytes.Buffer
+	c := &httpclient.LoggingClient{
+		Caller:     &httpclient.BaseClient{},
+		Logger:     slog.New(slog.NewJSONHandler(&output, &slog.HandlerOptions{Level: slog.LevelDebug})),
+		SampleRate: 1e-9,
+	}
+
+	for i := 0; i < 10; i++ {
+		_, err := doCall(c, s.URL+"/foo")
+		require.NoError(t, err)
+	}
+	_, err := doCall(c, s.URL+"/bar")
+	require.Error(t, err)
+	_, err = doCall(c, s.URL+"/error")
+	require.Error(t, err)
+
+	// failed calls are never sampled out
+	records := logRecords(t, &output)
+	require.Len(t, records, 2)
+	assert.Equal(t, float64(http.StatusNotFound), records[0]["status"])
+	assert.Equal(t, "WARN", records[1]["level"])
+	assert.Equal(t, float64(http.StatusInternalServerError), records[1]["status"])
+}
+
+func TestLoggingClient_Do_ConcurrentCacheOutcome(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+		handler(w, req)
+	}))
+	defer s.Close()
+
+	var output bytes.Buffer
+	// Hedger runs its attempts concurrently, so several Cachers report an outcome for the same call
+	c := &httpclient.LoggingClient{
+		Caller: &httpclient.Hedger{
+			Caller: &httpclient.Cacher{
+				Caller: &httpclient.BaseClient{},
+				Table:  httpclient.CacheTable{Table: []httpclient.CacheTableEntry{{Endpoint: "/bar"}}},
+				Cache:  cache.New[string, []byte](time.Minute, 0),
+			},
+			Delay:     time.Millisecond,
+			MaxHedges: 3,
+		},
+		Logger: slog.New(slog.NewJSONHandler(&output, &slog.HandlerOptions{Level: slog.LevelDebug})),
+	}
+
+	for i := 0; i < 5; i++ {
+		_, err := doCall(c, s.URL+"/foo")
+		require.NoError(t, err)
+	}
+
+	records := logRecords(t, &output)
+	require.Len(t, records, 5)
+	for _, record := range records {
+		assert.Equal(t, "miss", record["cache"])
+	}
+}
+
+func logRecords(t *testing.T, r io.Reader) []map[string]any {
+	t.Helper()
+	var records []map[string]any
+	decoder := json.NewDecoder(r)
+	for decoder.More() {
+		var record map[string]any
+		require.NoError(t, decoder.Decode(&record))
+		records = append(records, record)
+	}
+	return records
+}
@@ -1,59 +1,183 @@
 package httpclient
 
 import (
+	"context"
 	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/trace"
+	"time"
 )
 
-// Metrics contains Prometheus metrics to capture during API calls. Each metric is expected to have two labels:
-// the first will contain the application issuing the request. The second will contain the endpoint (i.e. Path) of the request.
+// Metrics contains Prometheus metrics to capture during API calls. Each metric has at least three labels:
+// the application issuing the request, the endpoint (i.e. Path) of the request and the request's method.
+// Any extra labels passed to NewMetrics follow these, and get their value from the request's context (see WithMetricLabel).
+//
+// If the request's context contains a valid OpenTelemetry span context, Metrics attaches an exemplar with the trace ID and span ID
+// to the latency observations and to the recorded errors. Since Prometheus summaries don't support exemplars, latency exemplars
+// require Metrics to be created with NewHistogramMetrics.
 type Metrics struct {
-	latency *prometheus.SummaryVec // measures latency of an API call
-	errors  *prometheus.CounterVec // measures any errors returned by an API call
+	latency  prometheus.ObserverVec // measures latency of an API call
+	transfer prometheus.ObserverVec // measures time to last byte of an API call
+	errors   *prometheus.CounterVec // measures any errors returned by an API call
+	labels   []string               // extra labels, set from the request's context
 }
 
-// NewMetrics creates a standard set of Prometheus metrics to capture during API calls.
-func NewMetrics(namespace, subsystem string) *Metrics {
+// NewMetrics creates a standard set of Prometheus metrics to capture during API calls. Latency is measured as a summary.
+// Any provided labels are added to the metrics. Their values are taken from the request's context. See WithMetricLabel.
+func NewMetrics(namespace, subsystem string, labels ...string) *Metrics {
+	labelNames := append([]string{"application", "endpoint", "method"}, labels...)
 	return &Metrics{
 		latency: prometheus.NewSummaryVec(prometheus.SummaryOpts{
 			Name: prometheus.BuildFQName(namespace, subsystem, "api_latency"),
 			Help: "latency of Reporter API calls",
-		}, []string{"application", "endpoint", "method"}),
-		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
-			Name: prometheus.BuildFQName(namespace, subsystem, "api_errors_total"),
-			Help: "Number of failed Reporter API calls",
-		}, []string{"application", "endpoint", "method"}),
+		}, labelNames),
+		transfer: prometheus.NewSummaryVec(prometheus.SummaryOpts{
+			Name: prometheus.BuildFQName(namespace, subsystem, "api_transfer_latency"),
+			Help: "time to last byte of Reporter API calls, including reading the response body",
+		}, labelNames),
+		errors: newErrorsMetric(namespace, subsystem, labelNames),
+		labels: labels,
 	}
 }
 
+// NewHistogramMetrics creates a standard set of Prometheus metrics to capture during API calls. Latency is measured as a histogram,
+// using the provided buckets. If buckets is nil, prometheus.DefBuckets is used.
+// Any provided labels are added to the metrics. Their values are taken from the request's context. See WithMetricLabel.
+func NewHistogramMetrics(namespace, subsystem string, buckets []float64, labels ...string) *Metrics {
+	labelNames := append([]string{"application", "endpoint", "method"}, labels...)
+	return &Metrics{
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    prometheus.BuildFQName(namespace, subsystem, "api_latency"),
+			Help:    "latency of Reporter API calls",
+			Buckets: buckets,
+		}, labelNames),
+		transfer: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    prometheus.BuildFQName(namespace, subsystem, "api_transfer_latency"),
+			Help:    "time to last byte of Reporter API calls, including reading the response body",
+			Buckets: buckets,
+		}, labelNames),
+		errors: newErrorsMetric(namespace, subsystem, labelNames),
+		labels: labels,
+	}
+}
+
+func newErrorsMetric(namespace, subsystem string, labelNames []string) *prometheus.CounterVec {
+	return prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: prometheus.BuildFQName(namespace, subsystem, "api_errors_total"),
+		Help: "Number of failed Reporter API calls",
+	}, labelNames)
+}
+
 var _ prometheus.Collector = &Metrics{}
+var _ MetricsRecorder = &Metrics{}
 
 // Describe implements the prometheus.Collector interface so clients can register Metrics as a whole
 func (pm *Metrics) Describe(ch chan<- *prometheus.Desc) {
 	pm.latency.Describe(ch)
+	pm.transfer.Describe(ch)
 	pm.errors.Describe(ch)
 }
 
 // Collect implements the prometheus.Collector interface so clients can register Metrics as a whole
 func (pm *Metrics) Collect(ch chan<- prometheus.Metric) {
 	pm.latency.Collect(ch)
+	pm.transfer.Collect(ch)
 	pm.errors.Collect(ch)
 }
 
-func (pm *Metrics) reportErrors(err error, labelValues ...string) {
+// ReportRequest implements the MetricsRecorder interface. It records the latency of the call and whether it failed.
+func (pm *Metrics) ReportRequest(ctx context.Context, call CallInfo, duration time.Duration, err error) {
+	labels := pm.labelValues(ctx, call.Application, call.Endpoint, call.Method)
+	pm.observeLatency(ctx, duration, labels...)
+	pm.reportErrors(ctx, err, labels...)
+}
+
+// ReportTransfer implements the MetricsRecorder interface. It records the time to last byte of the call. If reading the response body failed,
+// the call is recorded as an error.
+func (pm *Metrics) ReportTransfer(ctx context.Context, call CallInfo, duration time.Duration, err error) {
+	labels := pm.labelValues(ctx, call.Application, call.Endpoint, call.Method)
+	pm.observeTransfer(ctx, duration, labels...)
+	if err != nil {
+		pm.reportErrors(ctx, err, labels...)
+	}
+}
+
+func (pm *Metrics) reportErrors(ctx context.Context, err error, labelValues ...string) {
 	if pm == nil || pm.errors == nil {
 		return
 	}
 
-	var value float64
-	if err != nil {
-		value = 1.0
+	if err == nil {
+		pm.errors.WithLabelValues(labelValues...).Add(0)
+		return
+	}
+	counter := pm.errors.WithLabelValues(labelValues...)
+	if adder, ok := counter.(prometheus.ExemplarAdder); ok {
+		if exemplar := exemplarLabels(ctx); exemplar != nil {
+			adder.AddWithExemplar(1, exemplar)
+			return
+		}
 	}
-	pm.errors.WithLabelValues(labelValues...).Add(value)
+	counter.Add(1)
 }
 
-func (pm *Metrics) makeLatencyTimer(labelValues ...string) (timer *prometheus.Timer) {
+func (pm *Metrics) observeLatency(ctx context.Context, duration time.Duration, labelValues ...string) {
 	if pm != nil && pm.latency != nil {
-		timer = prometheus.NewTimer(pm.latency.WithLabelValues(labelValues...))
+		observe(ctx, pm.latency.WithLabelValues(labelValues...), duration)
+	}
+}
+
+func (pm *Metrics) observeTransfer(ctx context.Context, duration time.Duration, labelValues ...string) {
+	if pm != nil && pm.transfer != nil {
+		observe(ctx, pm.transfer.WithLabelValues(labelValues...), duration)
+	}
+}
+
+func observe(ctx context.Context, observer prometheus.Observer, duration time.Duration) {
+	if exemplarObserver, ok := observer.(prometheus.ExemplarObserver); ok {
+		if exemplar := exemplarLabels(ctx); exemplar != nil {
+			exemplarObserver.ObserveWithExemplar(duration.Seconds(), exemplar)
+			return
+		}
+	}
+	observer.Observe(duration.Seconds())
+}
+
+// exemplarLabels returns the trace ID and span ID of the span context in ctx, to be attached as an exemplar.
+// Returns nil if ctx doesn't hold a valid span context.
+func exemplarLabels(ctx context.Context) prometheus.Labels {
+	spanContext := trace.SpanContextFromContext(ctx)
+	if !spanContext.IsValid() {
+		return nil
+	}
+	return prometheus.Labels{
+		"trace_id": spanContext.TraceID().String(),
+		"span_id":  spanContext.SpanID().String(),
+	}
+}
+
+// labelValues appends the values of the extra labels, as found in the context, to the provided label values.
+func (pm *Metrics) labelValues(ctx context.Context, labelValues ...string) []string {
+	if pm == nil || len(pm.labels) == 0 {
+		return labelValues
+	}
+	values, _ := ctx.Value(metricLabelsKey{}).(map[string]string)
+	for _, label := range pm.labels {
+		labelValues = append(labelValues, values[label])
+	}
+	return labelValues
+}
+
+type metricLabelsKey struct{}
+
+// WithMetricLabel returns a copy of ctx that sets the value of the extra metric label. When the request's context holds a value
+// for any of the extra labels passed to NewMetrics, InstrumentedClient uses it for that label. Missing labels are left empty.
+// This allows a single InstrumentedClient to split its metrics, e.g. per tenant.
+func WithMetricLabel(ctx context.Context, label, value string) context.Context {
+	current, _ := ctx.Value(metricLabelsKey{}).(map[string]string)
+	values := make(map[string]string, len(current)+1)
+	for k, v := range current {
+		values[k] = v
 	}
-	return
+	values[label] = value
+	return context.WithValue(ctx, metricLabelsKey{}, values)
 }
@@ -1,31 +1,30 @@
 package httpclient
 
 import (
+	"context"
 	"errors"
 	"github.com/prometheus/client_golang/prometheus"
 	pcg "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
 	"net/http"
 	"testing"
 	"time"
 )
 
-func TestClientMetrics_MakeLatencyTimer(t *testing.T) {
+func TestClientMetrics_ObserveLatency(t *testing.T) {
 	cfg := &Metrics{}
 
-	// makeLatencyTimer returns nil if no latency metric is set
-	assert.Nil(t, cfg.makeLatencyTimer())
+	// observeLatency doesn't crash when no latency metric is set
+	cfg.observeLatency(context.Background(), time.Second)
 
 	r := prometheus.NewRegistry()
 	cfg = NewMetrics("foo", "")
 	r.MustRegister(cfg)
 
 	// collect metrics
-	timer := cfg.makeLatencyTimer("foo", "/bar", http.MethodGet)
-	require.NotNil(t, timer)
-	time.Sleep(10 * time.Millisecond)
-	timer.ObserveDuration()
+	cfg.observeLatency(context.Background(), 10*time.Millisecond, "foo", "/bar", http.MethodGet)
 
 	// one measurement should be collected
 	m, err := r.Gather()
@@ -43,37 +42,128 @@ func TestClientMetrics_MakeLatencyTimer(t *testing.T) {
 	assert.True(t, found)
 }
 
+func TestClientMetrics_ObserveTransfer(t *testing.T) {
+	cfg := &Metrics{}
+
+	// observeTransfer doesn't crash when no transfer metric is set
+	cfg.observeTransfer(context.Background(), time.Second)
+
+	cfg = NewMetrics("foo", "")
+	cfg.observeTransfer(context.Background(), time.Second, "foo", "/bar", http.MethodGet)
+
+	ch := make(chan prometheus.Metric, 10)
+	cfg.transfer.Collect(ch)
+	close(ch)
+	var count int
+	for range ch {
+		count++
+	}
+	assert.Equal(t, 1, count)
+}
+
+func TestClientMetrics_Report(t *testing.T) {
+	r := prometheus.NewRegistry()
+	cfg := NewMetrics("foo", "")
+	r.MustRegister(cfg)
+
+	call := CallInfo{Application: "foo", Endpoint: "/bar", Method: http.MethodGet}
+	cfg.ReportRequest(context.Background(), call, time.Second, nil)
+	cfg.ReportTransfer(context.Background(), call, time.Second, nil)
+	assert.Equal(t, map[string]float64{"/bar": 0}, getErrorMetrics(t, r, "foo_"))
+
+	cfg.ReportTransfer(context.Background(), call, time.Second, errors.New("truncated"))
+	assert.Equal(t, map[string]float64{"/bar": 1}, getErrorMetrics(t, r, "foo_"))
+}
+
 func TestClientMetrics_ReportErrors(t *testing.T) {
 	cfg := &Metrics{}
 
 	// reportErrors doesn't crash when no errors metric is set
-	cfg.reportErrors(nil)
+	cfg.reportErrors(context.Background(), nil)
 
 	r := prometheus.NewRegistry()
 	cfg = NewMetrics("bar", "")
 	r.MustRegister(cfg)
 
 	// collect metrics
-	cfg.reportErrors(nil, "foo", "/bar", http.MethodGet)
+	cfg.reportErrors(context.Background(), nil, "foo", "/bar", http.MethodGet)
 
 	// do a measurement
 	count := getErrorMetrics(t, r, "bar_")
 	assert.Equal(t, map[string]float64{"/bar": 0}, count)
 
 	// record an error
-	cfg.reportErrors(errors.New("some error"), "foo", "/bar", http.MethodGet)
+	cfg.reportErrors(context.Background(), errors.New("some error"), "foo", "/bar", http.MethodGet)
 
 	// counter should now be 1
 	count = getErrorMetrics(t, r, "bar_")
 	assert.Equal(t, map[string]float64{"/bar": 1}, count)
 }
 
+func TestClientMetrics_LabelValues(t *testing.T) {
+	var cfg *Metrics
+	assert.Equal(t, []string{"foo"}, cfg.labelValues(context.Background(), "foo"))
+
+	cfg = NewMetrics("foo", "", "tenant", "upstream")
+	ctx := WithMetricLabel(context.Background(), "tenant", "bar")
+	assert.Equal(t, []string{"foo", "/bar", http.MethodGet, "bar", ""}, cfg.labelValues(ctx, "foo", "/bar", http.MethodGet))
+
+	ctx2 := WithMetricLabel(ctx, "upstream", "snafu")
+	assert.Equal(t, []string{"bar", "snafu"}, cfg.labelValues(ctx2))
+	assert.Equal(t, []string{"bar", ""}, cfg.labelValues(ctx))
+}
+
+func TestClientMetrics_Exemplars(t *testing.T) {
+	r := prometheus.NewRegistry()
+	cfg := NewHistogramMetrics("foo", "", nil)
+	r.MustRegister(cfg)
+
+	traceID, _ := trace.TraceIDFromHex("0102030405060708090a0b0c0d0e0f10")
+	spanID, _ := trace.SpanIDFromHex("0102030405060708")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceID,
+		SpanID:  spanID,
+	}))
+
+	call := CallInfo{Application: "foo", Endpoint: "/bar", Method: http.MethodGet}
+	cfg.ReportRequest(ctx, call, 10*time.Millisecond, errors.New("some error"))
+
+	m, err := r.Gather()
+	require.NoError(t, err)
+	var exemplars []*pcg.Exemplar
+	for _, entry := range m {
+		switch *entry.Name {
+		case "foo_api_latency":
+			require.Equal(t, pcg.MetricType_HISTOGRAM, *entry.Type)
+			for _, bucket := range entry.Metric[0].Histogram.Bucket {
+				if bucket.Exemplar != nil {
+					exemplars = append(exemplars, bucket.Exemplar)
+				}
+			}
+		case "foo_api_errors_total":
+			exemplars = append(exemplars, entry.Metric[0].Counter.Exemplar)
+		}
+	}
+	require.Len(t, exemplars, 2)
+	for _, exemplar := range exemplars {
+		labels := make(map[string]string)
+		for _, label := range exemplar.Label {
+			labels[label.GetName()] = label.GetValue()
+		}
+		assert.Equal(t, map[string]string{"trace_id": traceID.String(), "span_id": spanID.String()}, labels)
+	}
+}
+
 func TestClientMetrics_Nil(t *testing.T) {
 	cfg := Metrics{}
 
-	timer := cfg.makeLatencyTimer("snafu")
-	assert.Nil(t, timer)
-	cfg.reportErrors(nil, "foo")
+	cfg.observeLatency(context.Background(), time.Second, "snafu")
+	cfg.observeTransfer(context.Background(), time.Second, "snafu")
+	cfg.reportErrors(context.Background(), nil, "foo")
+
+	var nilCfg *Metrics
+	nilCfg.ReportRequest(context.Background(), CallInfo{}, time.Second, nil)
+	nilCfg.ReportTransfer(context.Background(), CallInfo{}, time.Second, nil)
 }
 
 func getErrorMetrics(t *testing.T, g prometheus.Gatherer, prefix string) map[string]float64 {
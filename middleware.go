@@ -0,0 +1,53 @@
+package httpclient
+
+import (
+	"github.com/clambin/cache"
+	"net/http"
+)
+
+// CallerFunc is an adapter to allow the use of ordinary functions as a Caller.
+type CallerFunc func(req *http.Request) (*http.Response, error)
+
+var _ Caller = CallerFunc(nil)
+
+// Do calls f(req)
+func (f CallerFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Middleware wraps a Caller to add behaviour to it.
+type Middleware func(next Caller) Caller
+
+// Chain returns a Caller that sends requests through the provided middleware, before passing them to base.
+// The first middleware is the outermost layer, i.e. it sees the request first and the response last:
+//
+//	Chain(base, a, b) == a(b(base))
+func Chain(base Caller, middlewares ...Middleware) Caller {
+	c := base
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		c = middlewares[i](c)
+	}
+	return c
+}
+
+// CachingMiddleware returns a Middleware that caches responses, as per the provided cache table.
+// If table is empty, all responses are cached.
+func CachingMiddleware(table []CacheTableEntry, c cache.Cacher[string, []byte]) Middleware {
+	return func(next Caller) Caller {
+		return &Cacher{
+			Caller: next,
+			Table:  CacheTable{Table: table},
+			Cache:  c,
+		}
+	}
+}
+
+// InstrumentationMiddleware returns a Middleware that records performance metrics of the API calls, as InstrumentedClient does.
+// Placed in front of a caching middleware, it also measures calls that were served from cache.
+func InstrumentationMiddleware(application string, options Options) Middleware {
+	return func(next Caller) Caller {
+		return CallerFunc(func(req *http.Request) (*http.Response, error) {
+			return instrumentedDo(next, application, options, req)
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package httpclient_test
+
+import (
+	"github.com/clambin/cache"
+	"github.com/clambin/httpclient"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestChain(t *testing.T) {
+	var order []string
+	layer := func(name string) httpclient.Middleware {
+		return func(next httpclient.Caller) httpclient.Caller {
+			return httpclient.CallerFunc(func(req *http.Request) (*http.Response, error) {
+				order = append(order, name)
+				return next.Do(req)
+			})
+		}
+	}
+	base := httpclient.CallerFunc(func(req *http.Request) (*http.Response, error) {
+		order = append(order, "base")
+		return &http.Response{StatusCode: http.StatusOK}, nil
+	})
+
+	c := httpclient.Chain(base, layer("a"), layer("b"))
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	resp, err := c.Do(req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, []string{"a", "b", "base"}, order)
+
+	order = nil
+	_, err = httpclient.Chain(base).Do(req)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"base"}, order)
+}
+
+func TestChain_CachingAndInstrumentation(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	for _, tc := range []struct {
+		name        string
+		measureHits bool
+		want        uint64
+	}{
+		{name: "instrumentation behind cache", measureHits: false, want: 1},
+		{name: "instrumentation in front of cache", measureHits: true, want: 3},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := prometheus.NewRegistry()
+			metrics := httpclient.NewMetrics("foo", "bar")
+			r.MustRegister(metrics)
+
+			caching := httpclient.CachingMiddleware(nil, cache.New[string, []byte](time.Minute, 0))
+			instrumentation := httpclient.InstrumentationMiddleware("foo", httpclient.Options{PrometheusMetrics: metrics})
+			middlewares := []httpclient.Middleware{caching, instrumentation}
+			if tc.measureHits {
+				middlewares = []httpclient.Middleware{instrumentation, caching}
+			}
+			c := httpclient.Chain(&httpclient.BaseClient{}, middlewares...)
+
+			for i := 0; i < 3; i++ {
+				response, err := doCall(c, s.URL+"/foo")
+				require.NoError(t, err)
+				assert.Equal(t, 42, response.Age)
+			}
+
+			assert.Equal(t, map[string]uint64{"/foo": tc.want}, getLatencyCounters(t, r, "foo_bar_"))
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package httpclient
+
+import (
+	"errors"
+	"github.com/clambin/cache"
+	"log/slog"
+	"net/http"
+)
+
+// Option configures the Caller created by New
+type Option func(*settings)
+
+type settings struct {
+	httpClient    *http.Client
+	application   string
+	metrics       *Options
+	cacheTable    []CacheTableEntry
+	cacheStore    cache.Cacher[string, []byte]
+	retries       int
+	logger        *slog.Logger
+	middlewares   []Middleware
+	errs          []error
+	hasCacheTable bool
+}
+
+// New creates a Caller from the provided options. Each option adds a layer to the Caller. Layers that aren't configured are left out.
+// The layers are stacked in a fixed order, from the outermost to the innermost:
+//
+//   - logging (WithLogging)
+//   - caching (WithCache)
+//   - retries (WithRetries)
+//   - instrumentation (WithMetrics)
+//   - custom middleware (WithMiddleware)
+//   - the HTTP client (WithHTTPClient)
+//
+// So calls served from cache are logged, but not instrumented, and each retry is instrumented as a separate call.
+func New(opts ...Option) (Caller, error) {
+	s := settings{}
+	for _, opt := range opts {
+		opt(&s)
+	}
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
+
+	var middlewares []Middleware
+	if s.logger != nil {
+		middlewares = append(middlewares, func(next Caller) Caller { return &LoggingClient{Caller: next, Logger: s.logger} })
+	}
+	if s.cacheStore != nil {
+		middlewares = append(middlewares, CachingMiddleware(s.cacheTable, s.cacheStore))
+	}
+	if s.retries > 0 {
+		middlewares = append(middlewares, func(next Caller) Caller {
+			return &Retrier{Caller: next, MaxAttempts: s.retries, Application: s.application}
+		})
+	}
+	if s.metrics != nil {
+		middlewares = append(middlewares, InstrumentationMiddleware(s.application, *s.metrics))
+	}
+	middlewares = append(middlewares, s.middlewares...)
+
+	return Chain(&BaseClient{HTTPClient: s.httpClient}, middlewares...), nil
+}
+
+func (s settings) validate() error {
+	errs := s.errs
+	if s.hasCacheTable && s.cacheStore == nil {
+		errs = append(errs, errors.New("cache table requires a cache store"))
+	}
+	return errors.Join(errs...)
+}
+
+// WithHTTPClient sets the http.Client that performs the requests. Default: http.DefaultClient
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(s *settings) {
+		if httpClient == nil {
+			s.errs = append(s.errs, errors.New("http client cannot be nil"))
+		}
+		s.httpClient = httpClient
+	}
+}
+
+// WithApplication sets the application name, used as the application label in metrics
+func WithApplication(application string) Option {
+	return func(s *settings) {
+		s.application = application
+	}
+}
+
+// WithMetrics records metrics of each API call, as InstrumentedClient does
+func WithMetrics(options Options) Option {
+	return func(s *settings) {
+		if options.PrometheusMetrics == nil && options.Metrics == nil {
+			s.errs = append(s.errs, errors.New("metrics options have no metrics"))
+		}
+		s.metrics = &options
+	}
+}
+
+// WithCache caches responses in the provided store. Use WithCacheTable to select which responses are cached. By default, all responses are cached.
+func WithCache(store cache.Cacher[string, []byte]) Option {
+	return func(s *settings) {
+		if store == nil {
+			s.errs = append(s.errs, errors.New("cache store cannot be nil"))
+		}
+		s.cacheStore = store
+	}
+}
+
+// WithCacheTable determines which responses are cached. Requires WithCache.
+func WithCacheTable(table []CacheTableEntry) Option {
+	return func(s *settings) {
+		s.cacheTable = table
+		s.hasCacheTable = true
+	}
+}
+
+// WithRetries retries failed requests, as Retrier does, up to maxAttempts attempts in total
+func WithRetries(maxAttempts int) Option {
+	return func(s *settings) {
+		if maxAttempts < 1 {
+			s.errs = append(s.errs, errors.New("max attempts must be at least 1"))
+		}
+		s.retries = maxAttempts
+	}
+}
+
+// WithLogging logs each API call, as LoggingClient does
+func WithLogging(logger *slog.Logger) Option {
+	return func(s *settings) {
+		if logger == nil {
+			s.errs = append(s.errs, errors.New("logger cannot be nil"))
+		}
+		s.logger = logger
+	}
+}
+
+// WithMiddleware adds custom middleware, e.g. an Authenticator or a SigningClient, directly on top of the HTTP client.
+// The first middleware is the outermost layer.
+func WithMiddleware(middlewares ...Middleware) Option {
+	return func(s *settings) {
+		s.middlewares = append(s.middlewares, middlewares...)
+	}
+}
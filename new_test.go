@@ -0,0 +1,97 @@
+package httpclient_test
+
+import (
+	"bytes"
+	"github.com/clambin/cache"
+	"github.com/clambin/httpclient"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNew(t *testing.T) {
+	var calls atomic.Int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		// fail the first call, so it gets retried
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		handler(w, req)
+	}))
+	defer s.Close()
+
+	r := prometheus.NewRegistry()
+	metrics := httpclient.NewMetrics("foo", "")
+	r.MustRegister(metrics)
+	var logs bytes.Buffer
+	var layers []string
+
+	c, err := httpclient.New(
+		httpclient.WithHTTPClient(s.Client()),
+		httpclient.WithApplication("foo"),
+		httpclient.WithMetrics(httpclient.Options{PrometheusMetrics: metrics}),
+		httpclient.WithCache(cache.New[string, []byte](time.Minute, 0)),
+		httpclient.WithCacheTable([]httpclient.CacheTableEntry{{Endpoint: "/foo"}}),
+		httpclient.WithRetries(2),
+		httpclient.WithLogging(slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))),
+		httpclient.WithMiddleware(func(next httpclient.Caller) httpclient.Caller {
+			return httpclient.CallerFunc(func(req *http.Request) (*http.Response, error) {
+				layers = append(layers, req.URL.Path)
+				return next.Do(req)
+			})
+		}),
+	)
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		resp, err := doCall(c, s.URL+"/foo")
+		require.NoError(t, err)
+		assert.Equal(t, testStruct{Name: "bar", Age: 42}, resp)
+	}
+
+	// first call was retried, second call was served from cache
+	assert.Equal(t, int32(2), calls.Load())
+	assert.Equal(t, []string{"/foo", "/foo"}, layers)
+	assert.Equal(t, map[string]float64{"/foo": 0}, getErrorMetrics(t, r, "foo_"))
+	assert.Equal(t, 2, strings.Count(logs.String(), "http call"))
+	assert.Contains(t, logs.String(), "cache=hit")
+}
+
+func TestNew_Defaults(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	c, err := httpclient.New()
+	require.NoError(t, err)
+	resp, err := doCall(c, s.URL+"/foo")
+	require.NoError(t, err)
+	assert.Equal(t, testStruct{Name: "bar", Age: 42}, resp)
+}
+
+func TestNew_Validation(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		opt  httpclient.Option
+	}{
+		{name: "nil http client", opt: httpclient.WithHTTPClient(nil)},
+		{name: "no metrics", opt: httpclient.WithMetrics(httpclient.Options{})},
+		{name: "nil cache", opt: httpclient.WithCache(nil)},
+		{name: "cache table without cache", opt: httpclient.WithCacheTable([]httpclient.CacheTableEntry{{Endpoint: "/foo"}})},
+		{name: "invalid retries", opt: httpclient.WithRetries(0)},
+		{name: "nil logger", opt: httpclient.WithLogging(nil)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := httpclient.New(tc.opt)
+			assert.Error(t, err)
+			assert.Nil(t, c)
+		})
+	}
+}
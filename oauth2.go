@@ -0,0 +1,263 @@
+package httpclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OAuth2Error is returned when the token endpoint rejects a token request
+type OAuth2Error struct {
+	StatusCode  int    `json:"-"`
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+// Error implements the error interface
+func (e *OAuth2Error) Error() string {
+	msg := "oauth2: token request failed: " + http.StatusText(e.StatusCode)
+	if e.Code != "" {
+		msg += ": " + e.Code
+	}
+	if e.Description != "" {
+		msg += " (" + e.Description + ")"
+	}
+	return msg
+}
+
+// OAuth2TokenSource is a TokenSource that gets access tokens from an OAuth2 token endpoint. If RefreshToken is set,
+// it uses the refresh-token grant. Otherwise, it uses the client-credentials grant.
+//
+// OAuth2TokenSource caches the access token until shortly before it expires. Concurrent requests for an expired token
+// result in a single call to the token endpoint. The token request is not cancelled when the caller's context is cancelled,
+// so it can still serve other callers. Use Caller to set a timeout on token requests.
+type OAuth2TokenSource struct {
+	// Caller sends requests to the token endpoint. Default: BaseClient
+	Caller Caller
+	// TokenURL is the URL of the token endpoint
+	TokenURL string
+	// ClientID and ClientSecret identify the client
+	ClientID     string
+	ClientSecret string
+	// AuthInParams sends the client credentials as form parameters, rather than using basic authentication
+	AuthInParams bool
+	// Scopes lists the requested scopes
+	Scopes []string
+	// RefreshToken uses the refresh-token grant. If the token endpoint returns a new refresh token, it replaces this one.
+	RefreshToken string
+	// Params are added to each token request
+	Params url.Values
+	// ExpiryDelta refreshes the token this long before it expires. Default: 10s
+	ExpiryDelta time.Duration
+	// Metrics records the latency and errors of token requests
+	Metrics MetricsRecorder
+	// Application is the application label for Metrics
+	Application string
+
+	lock         sync.Mutex
+	token        string
+	expiry       time.Time
+	refreshToken string
+	inflight     *tokenCall
+}
+
+type tokenCall struct {
+	done  chan struct{}
+	token string
+	err   error
+}
+
+var _ TokenSource = &OAuth2TokenSource{}
+
+// Token returns a valid access token, requesting a new one from the token endpoint if needed
+func (s *OAuth2TokenSource) Token(ctx context.Context) (string, error) {
+	s.lock.Lock()
+	if s.token != "" && (s.expiry.IsZero() || time.Now().Before(s.expiry)) {
+		token := s.token
+		s.lock.Unlock()
+		return token, nil
+	}
+	call := s.inflight
+	if call == nil {
+		call = &tokenCall{done: make(chan struct{})}
+		s.inflight = call
+		go s.refresh(context.WithoutCancel(ctx), call)
+	}
+	s.lock.Unlock()
+
+	select {
+	case <-call.done:
+		return call.token, call.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// Invalidate discards the cached access token, if it's still the provided token. The next call to Token requests a new one.
+func (s *OAuth2TokenSource) Invalidate(token string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.token == token {
+		s.token = ""
+	}
+}
+
+func (s *OAuth2TokenSource) refresh(ctx context.Context, call *tokenCall) {
+	s.lock.Lock()
+	refreshToken := s.refreshToken
+	if refreshToken == "" {
+		refreshToken = s.RefreshToken
+	}
+	s.lock.Unlock()
+
+	resp, err := s.requestToken(ctx, refreshToken)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err == nil {
+		s.token = resp.AccessToken
+		s.expiry = time.Time{}
+		if resp.ExpiresIn > 0 {
+			s.expiry = time.Now().Add(time.Duration(resp.ExpiresIn)*time.Second - s.expiryDelta())
+		}
+		if resp.RefreshToken != "" {
+			s.refreshToken = resp.RefreshToken
+		}
+	}
+	call.token, call.err = resp.AccessToken, err
+	s.inflight = nil
+	close(call.done)
+}
+
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+func (s *OAuth2TokenSource) requestToken(ctx context.Context, refreshToken string) (tokenResponse, error) {
+	form := url.Values{}
+	for key, values := range s.Params {
+		form[key] = append([]string(nil), values...)
+	}
+	if refreshToken != "" {
+		form.Set("grant_type", "refresh_token")
+		form.Set("refresh_token", refreshToken)
+	} else {
+		form.Set("grant_type", "client_credentials")
+	}
+	if len(s.Scopes) > 0 {
+		form.Set("scope", strings.Join(s.Scopes, " "))
+	}
+	if s.AuthInParams {
+		form.Set("client_id", s.ClientID)
+		form.Set("client_secret", s.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return tokenResponse{}, fmt.Errorf("oauth2: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if !s.AuthInParams {
+		req.SetBasicAuth(url.QueryEscape(s.ClientID), url.QueryEscape(s.ClientSecret))
+	}
+
+	caller := s.Caller
+	if caller == nil {
+		caller = &BaseClient{}
+	}
+	host, port := hostAndPort(req)
+	call := CallInfo{Application: s.Application, Endpoint: req.URL.Path, Method: req.Method, ServerAddress: host, ServerPort: port}
+
+	start := time.Now()
+	var token tokenResponse
+	resp, err := caller.Do(req)
+	if err == nil {
+		call.StatusCode = resp.StatusCode
+		token, err = parseTokenResponse(resp)
+	}
+	if s.Metrics != nil {
+		s.Metrics.ReportRequest(ctx, call, time.Since(start), err)
+	}
+	return token, err
+}
+
+func parseTokenResponse(resp *http.Response) (tokenResponse, error) {
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return tokenResponse{}, fmt.Errorf("oauth2: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		oauthErr := OAuth2Error{StatusCode: resp.StatusCode}
+		_ = json.Unmarshal(body, &oauthErr)
+		return tokenResponse{}, &oauthErr
+	}
+	var token tokenResponse
+	if err = json.Unmarshal(body, &token); err != nil {
+		return tokenResponse{}, fmt.Errorf("oauth2: invalid token response: %w", err)
+	}
+	if token.AccessToken == "" {
+		return tokenResponse{}, fmt.Errorf("oauth2: token response has no access token")
+	}
+	return token, nil
+}
+
+func (s *OAuth2TokenSource) expiryDelta() time.Duration {
+	if s.ExpiryDelta == 0 {
+		return 10 * time.Second
+	}
+	return s.ExpiryDelta
+}
+
+// OAuth2Client implements the Caller interface. It adds an OAuth2 access token to each request. If the server rejects
+// the token with a 401, OAuth2Client discards the token, gets a new one and retries the request once. Requests with a body
+// are only retried if the body can be rewound (i.e. GetBody is set).
+//
+// To only add tokens for specific hosts, use the OAuth2TokenSource in an Authenticator instead (this doesn't retry on a 401).
+type OAuth2Client struct {
+	Caller
+	Source *OAuth2TokenSource
+}
+
+var _ Caller = &OAuth2Client{}
+
+// Do adds the access token to the request and sends it
+func (c *OAuth2Client) Do(req *http.Request) (*http.Response, error) {
+	token, err := c.Source.Token(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	clone := req.Clone(req.Context())
+	if clone.Header == nil {
+		clone.Header = make(http.Header)
+	}
+	clone.Header.Set("Authorization", "Bearer "+token)
+	resp, err := c.Caller.Do(clone)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || !canRewind(req) {
+		return resp, err
+	}
+
+	drainAndClose(resp)
+	c.Source.Invalidate(token)
+	if token, err = c.Source.Token(req.Context()); err != nil {
+		return nil, err
+	}
+	if clone, err = rewind(req); err != nil {
+		return nil, err
+	}
+	if clone.Header == nil {
+		clone.Header = make(http.Header)
+	}
+	clone.Header.Set("Authorization", "Bearer "+token)
+	return c.Caller.Do(clone)
+}
@@ -0,0 +1,226 @@
+package httpclient_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/clambin/httpclient"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type tokenServer struct {
+	calls     atomic.Int32
+	expiresIn int
+	delay     time.Duration
+	lock      sync.Mutex
+	forms     []map[string]string
+}
+
+func (s *tokenServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	count := s.calls.Add(1)
+	time.Sleep(s.delay)
+	_ = req.ParseForm()
+	form := make(map[string]string)
+	for key := range req.PostForm {
+		form[key] = req.PostForm.Get(key)
+	}
+	if id, secret, ok := req.BasicAuth(); ok {
+		form["basic"] = id + ":" + secret
+	}
+	s.lock.Lock()
+	s.forms = append(s.forms, form)
+	s.lock.Unlock()
+
+	if form["basic"] != "client:secret" && form["client_secret"] != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"error":"invalid_client","error_description":"bad credentials"}`))
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"access_token":  "token-" + strconv.Itoa(int(count)),
+		"token_type":    "Bearer",
+		"expires_in":    s.expiresIn,
+		"refresh_token": "refresh-" + strconv.Itoa(int(count)),
+	})
+}
+
+func TestOAuth2TokenSource_Token(t *testing.T) {
+	ts := &tokenServer{expiresIn: 3600}
+	s := httptest.NewServer(ts)
+	defer s.Close()
+
+	source := &httpclient.OAuth2TokenSource{TokenURL: s.URL + "/token", ClientID: "client", ClientSecret: "secret", Scopes: []string{"read", "write"}}
+	token, err := source.Token(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "token-1", token)
+
+	// token is cached
+	token, err = source.Token(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "token-1", token)
+	assert.Equal(t, map[string]string{"grant_type": "client_credentials", "scope": "read write", "basic": "client:secret"}, ts.forms[0])
+
+	// invalidating an older token has no effect
+	source.Invalidate("token-0")
+	token, _ = source.Token(context.Background())
+	assert.Equal(t, "token-1", token)
+
+	// after invalidating, the refresh token is used
+	source.Invalidate("token-1")
+	token, err = source.Token(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "token-2", token)
+	assert.Equal(t, "refresh_token", ts.forms[1]["grant_type"])
+	assert.Equal(t, "refresh-1", ts.forms[1]["refresh_token"])
+}
+
+func TestOAuth2TokenSource_Token_Expiry(t *testing.T) {
+	ts := &tokenServer{expiresIn: 1}
+	s := httptest.NewServer(ts)
+	defer s.Close()
+
+	source := &httpclient.OAuth2TokenSource{TokenURL: s.URL, ClientID: "client", ClientSecret: "secret", AuthInParams: true, ExpiryDelta: time.Second}
+	for i := 1; i <= 3; i++ {
+		token, err := source.Token(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "token-"+strconv.Itoa(i), token)
+	}
+	assert.Equal(t, "secret", ts.forms[0]["client_secret"])
+}
+
+func TestOAuth2TokenSource_Token_Singleflight(t *testing.T) {
+	ts := &tokenServer{expiresIn: 3600, delay: 100 * time.Millisecond}
+	s := httptest.NewServer(ts)
+	defer s.Close()
+
+	source := &httpclient.OAuth2TokenSource{TokenURL: s.URL, ClientID: "client", ClientSecret: "secret"}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			token, err := source.Token(context.Background())
+			assert.NoError(t, err)
+			assert.Equal(t, "token-1", token)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), ts.calls.Load())
+}
+
+func TestOAuth2TokenSource_Token_Errors(t *testing.T) {
+	ts := &tokenServer{expiresIn: 3600, delay: 100 * time.Millisecond}
+	s := httptest.NewServer(ts)
+	defer s.Close()
+
+	r := prometheus.NewRegistry()
+	metrics := httpclient.NewMetrics("foo", "")
+	r.MustRegister(metrics)
+
+	source := &httpclient.OAuth2TokenSource{TokenURL: s.URL + "/token", ClientID: "client", ClientSecret: "wrong", Metrics: metrics, Application: "foo"}
+	_, err := source.Token(context.Background())
+	var oauthErr *httpclient.OAuth2Error
+	require.ErrorAs(t, err, &oauthErr)
+	assert.Equal(t, http.StatusUnauthorized, oauthErr.StatusCode)
+	assert.Equal(t, "invalid_client", oauthErr.Code)
+	assert.Equal(t, "oauth2: token request failed: Unauthorized: invalid_client (bad credentials)", err.Error())
+	assert.Equal(t, map[string]float64{"/token": 1}, getErrorMetrics(t, r, "foo_"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = source.Token(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestOAuth2Client_Do(t *testing.T) {
+	ts := &tokenServer{expiresIn: 3600}
+	tokens := httptest.NewServer(ts)
+	defer tokens.Close()
+
+	var lock sync.Mutex
+	var received []string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		body := make([]byte, 3)
+		n, _ := req.Body.Read(body)
+		received = append(received, req.Header.Get("Authorization")+" "+string(body[:n]))
+		// reject the first token
+		if req.Header.Get("Authorization") == "Bearer token-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer api.Close()
+
+	c := &httpclient.OAuth2Client{
+		Caller: &httpclient.BaseClient{},
+		Source: &httpclient.OAuth2TokenSource{TokenURL: tokens.URL, ClientID: "client", ClientSecret: "secret"},
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, api.URL, strings.NewReader("foo"))
+	resp, err := c.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, []string{"Bearer token-1 foo", "Bearer token-2 foo"}, received)
+	assert.Empty(t, req.Header.Get("Authorization"))
+
+	// an invalidated token is renewed on the next request
+	c.Source.Invalidate("token-2")
+	c.Source.RefreshToken = ""
+	received = nil
+	req, _ = http.NewRequest(http.MethodGet, api.URL, nil)
+	resp, err = c.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, []string{"Bearer token-3 "}, received)
+}
+
+func TestOAuth2Client_Do_RetryOnce(t *testing.T) {
+	ts := &tokenServer{expiresIn: 3600}
+	tokens := httptest.NewServer(ts)
+	defer tokens.Close()
+
+	var calls int
+	c := &httpclient.OAuth2Client{
+		Caller: httpclient.CallerFunc(func(*http.Request) (*http.Response, error) {
+			calls++
+			return &http.Response{StatusCode: http.StatusUnauthorized, Body: http.NoBody}, nil
+		}),
+		Source: &httpclient.OAuth2TokenSource{TokenURL: tokens.URL, ClientID: "client", ClientSecret: "secret"},
+	}
+	// requests without headers are accepted
+	req, _ := http.NewRequest(http.MethodGet, "http://localhost:1", nil)
+	req.Header = nil
+	resp, err := c.Do(req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, int32(2), ts.calls.Load())
+}
+
+func TestOAuth2Client_Do_TokenError(t *testing.T) {
+	c := &httpclient.OAuth2Client{
+		Caller: &httpclient.BaseClient{},
+		Source: &httpclient.OAuth2TokenSource{
+			TokenURL: "http://localhost:1",
+			Caller: httpclient.CallerFunc(func(*http.Request) (*http.Response, error) {
+				return nil, errors.New("token endpoint down")
+			}),
+		},
+	}
+	req, _ := http.NewRequest(http.MethodGet, "http://localhost:1", nil)
+	_, err := c.Do(req)
+	assert.ErrorContains(t, err, "token endpoint down")
+}
@@ -0,0 +1,83 @@
+package httpclient
+
+import (
+	"context"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"strconv"
+	"time"
+)
+
+// OTelMetrics implements the MetricsRecorder interface using OpenTelemetry metrics. It records the standard http.client.request.duration
+// histogram, following the HTTP semantic conventions. It also records the time to last byte in a separate http.client.transfer.duration histogram.
+//
+// Besides the standard attributes, each measurement has an "application" attribute and the endpoint, as url.template.
+// Any extra labels passed to NewOTelMetrics are added as attributes, with their value taken from the request's context (see WithMetricLabel).
+type OTelMetrics struct {
+	duration metric.Float64Histogram
+	transfer metric.Float64Histogram
+	labels   []string
+}
+
+var _ MetricsRecorder = &OTelMetrics{}
+
+// durationBuckets are the bucket boundaries advised by the HTTP semantic conventions
+var durationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.075, 0.1, 0.25, 0.5, 0.75, 1, 2.5, 5, 7.5, 10}
+
+// NewOTelMetrics creates the OpenTelemetry instruments to record API performance metrics.
+func NewOTelMetrics(meter metric.Meter, labels ...string) (*OTelMetrics, error) {
+	duration, err := meter.Float64Histogram("http.client.request.duration",
+		metric.WithDescription("Duration of HTTP client requests."),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(durationBuckets...),
+	)
+	if err != nil {
+		return nil, err
+	}
+	transfer, err := meter.Float64Histogram("http.client.transfer.duration",
+		metric.WithDescription("Duration of HTTP client requests, including reading the response body."),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(durationBuckets...),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &OTelMetrics{duration: duration, transfer: transfer, labels: labels}, nil
+}
+
+// ReportRequest implements the MetricsRecorder interface. It records the call in the http.client.request.duration histogram.
+func (m *OTelMetrics) ReportRequest(ctx context.Context, call CallInfo, duration time.Duration, err error) {
+	m.duration.Record(ctx, duration.Seconds(), metric.WithAttributes(m.attributes(ctx, call, err)...))
+}
+
+// ReportTransfer implements the MetricsRecorder interface. It records the call in the http.client.transfer.duration histogram.
+func (m *OTelMetrics) ReportTransfer(ctx context.Context, call CallInfo, duration time.Duration, err error) {
+	m.transfer.Record(ctx, duration.Seconds(), metric.WithAttributes(m.attributes(ctx, call, err)...))
+}
+
+func (m *OTelMetrics) attributes(ctx context.Context, call CallInfo, err error) []attribute.KeyValue {
+	attributes := append(methodAttributes(call.Method),
+		attribute.String("application", call.Application),
+		semconv.URLTemplate(call.Endpoint),
+		semconv.ServerAddress(call.ServerAddress),
+	)
+	if call.ServerPort > 0 {
+		attributes = append(attributes, semconv.ServerPort(call.ServerPort))
+	}
+	if call.StatusCode > 0 {
+		attributes = append(attributes, semconv.HTTPResponseStatusCode(call.StatusCode))
+	}
+	switch {
+	case err != nil:
+		attributes = append(attributes, semconv.ErrorType(err))
+	case call.StatusCode >= 400:
+		attributes = append(attributes, semconv.ErrorTypeKey.String(strconv.Itoa(call.StatusCode)))
+	}
+
+	values, _ := ctx.Value(metricLabelsKey{}).(map[string]string)
+	for _, label := range m.labels {
+		attributes = append(attributes, attribute.String(label, values[label]))
+	}
+	return attributes
+}
@@ -0,0 +1,68 @@
+package httpclient_test
+
+import (
+	"context"
+	"github.com/clambin/httpclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOTelMetrics(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	meter := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("test")
+	metrics, err := httpclient.NewOTelMetrics(meter, "tenant")
+	require.NoError(t, err)
+
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	c := &httpclient.InstrumentedClient{
+		Options:     httpclient.Options{Metrics: metrics},
+		Application: "foo",
+	}
+
+	ctx := httpclient.WithMetricLabel(context.Background(), "tenant", "bar")
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, s.URL+"/foo", nil)
+	resp, err := c.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	req, _ = http.NewRequestWithContext(ctx, http.MethodGet, s.URL+"/bar", nil)
+	resp, err = c.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	require.Len(t, rm.ScopeMetrics, 1)
+
+	counts := make(map[string]map[string]uint64)
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		hist, ok := m.Data.(metricdata.Histogram[float64])
+		require.True(t, ok)
+		counts[m.Name] = make(map[string]uint64)
+		for _, dp := range hist.DataPoints {
+			endpoint, _ := dp.Attributes.Value("url.template")
+			status, _ := dp.Attributes.Value("http.response.status_code")
+			tenant, _ := dp.Attributes.Value("tenant")
+			assert.Equal(t, "bar", tenant.AsString())
+			application, _ := dp.Attributes.Value("application")
+			assert.Equal(t, "foo", application.AsString())
+			if errorType, ok := dp.Attributes.Value("error.type"); ok {
+				assert.Equal(t, "404", errorType.AsString())
+				assert.Equal(t, int64(404), status.AsInt64())
+			}
+			counts[m.Name][endpoint.AsString()] = dp.Count
+		}
+	}
+
+	assert.Equal(t, map[string]map[string]uint64{
+		"http.client.request.duration":  {"/foo": 1, "/bar": 1},
+		"http.client.transfer.duration": {"/foo": 1, "/bar": 1},
+	}, counts)
+}
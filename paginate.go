@@ -0,0 +1,285 @@
+package httpclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Page is a single page returned by Pages. Its Response body has already been read into Body and closed.
+type Page struct {
+	Response *http.Response
+	Body     []byte
+}
+
+// Paginator determines the request for the next page
+type Paginator interface {
+	// Next returns the request for the page following the provided page, or nil if there are no more pages.
+	Next(req *http.Request, page Page) (*http.Request, error)
+}
+
+// Pages returns an iterator over the pages of a paginated API, starting with req. Each page request is sent through c,
+// so any caching, instrumentation, etc. applies per page. The iterator stops after maxPages pages (zero means no limit),
+// when the Paginator finds no next page, or after yielding an error. A non-2xx response yields an *HTTPError.
+// A page larger than the maximum body size (see WithMaxBodySize) yields ErrBodyTooLarge. The iterator can be used more than once.
+func Pages(c Caller, req *http.Request, paginator Paginator, maxPages int, opts ...JSONOption) iter.Seq2[Page, error] {
+	return func(yield func(Page, error) bool) {
+		for cur, count := req, 1; cur != nil; count++ {
+			if err := cur.Context().Err(); err != nil {
+				yield(Page{}, err)
+				return
+			}
+			page, err := getPage(c, cur, opts)
+			if err != nil {
+				yield(Page{}, err)
+				return
+			}
+			if !yield(page, nil) || (maxPages > 0 && count >= maxPages) {
+				return
+			}
+			next, err := paginator.Next(cur, page)
+			if err != nil {
+				yield(Page{}, err)
+				return
+			}
+			if next != nil && next.URL.String() == cur.URL.String() {
+				// the next page is the same as the current one
+				return
+			}
+			cur = next
+		}
+	}
+}
+
+// PagesJSON returns an iterator over the pages of a paginated API, decoding each page as a T. See Pages.
+func PagesJSON[T any](c Caller, req *http.Request, paginator Paginator, maxPages int, opts ...JSONOption) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for page, err := range Pages(c, req, paginator, maxPages, opts...) {
+			var t T
+			if err == nil {
+				if err = json.Unmarshal(page.Body, &t); err != nil {
+					err = fmt.Errorf("decode: %w", err)
+				}
+			}
+			if !yield(t, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+func getPage(c Caller, req *http.Request, opts []JSONOption) (Page, error) {
+	if req.Header.Get("Accept") == "" {
+		req.Header.Set("Accept", "application/json")
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return Page{}, err
+	}
+	defer drainAndClose(resp)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return Page{}, NewHTTPError(req, resp)
+	}
+	body, err := readBodyLimited(resp, opts)
+	if err != nil {
+		return Page{}, err
+	}
+	return Page{Response: resp, Body: body}, nil
+}
+
+// nextRequest returns a copy of req for the provided URL
+func nextRequest(req *http.Request, u *url.URL) (*http.Request, error) {
+	next, err := rewind(req)
+	if err != nil {
+		return nil, err
+	}
+	next.URL = u
+	next.Host = ""
+	return next, nil
+}
+
+// LinkPaginator follows the "next" link in the response's Link header, as defined in RFC 8288
+type LinkPaginator struct{}
+
+var _ Paginator = LinkPaginator{}
+
+// Next returns the request for the "next" link, or nil if the response has no such link
+func (LinkPaginator) Next(req *http.Request, page Page) (*http.Request, error) {
+	for _, header := range page.Response.Header.Values("Link") {
+		for _, link := range splitLinkHeader(header, ',') {
+			parts := splitLinkHeader(link, ';')
+			target := strings.TrimSpace(parts[0])
+			if len(parts) < 2 || !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") || !isNextLink(parts[1:]) {
+				continue
+			}
+			u, err := req.URL.Parse(target[1 : len(target)-1])
+			if err != nil {
+				return nil, fmt.Errorf("link: %w", err)
+			}
+			return nextRequest(req, u)
+		}
+	}
+	return nil, nil
+}
+
+// splitLinkHeader splits a Link header value on sep, ignoring any separators inside a <URI> or a quoted string
+func splitLinkHeader(value string, sep byte) []string {
+	var parts []string
+	var inURI, inQuote bool
+	start := 0
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; {
+		case inQuote:
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				inQuote = false
+			}
+		case inURI:
+			inURI = c != '>'
+		case c == '"':
+			inQuote = true
+		case c == '<':
+			inURI = true
+		case c == sep:
+			parts = append(parts, value[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, value[start:])
+}
+
+func isNextLink(params []string) bool {
+	for _, param := range params {
+		name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+		if strings.EqualFold(strings.TrimSpace(name), "rel") {
+			for _, rel := range strings.Fields(strings.Trim(strings.TrimSpace(value), `"`)) {
+				if strings.EqualFold(rel, "next") {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// CursorPaginator reads the cursor for the next page from a field in the JSON response and passes it as a query parameter.
+// If the field is missing, empty or null, there are no more pages.
+type CursorPaginator struct {
+	// Field is the name of the JSON field holding the cursor. Use dots for nested fields, e.g. "meta.next_cursor".
+	Field string
+	// Param is the query parameter for the cursor. Default: cursor
+	Param string
+}
+
+var _ Paginator = CursorPaginator{}
+
+// Next returns the request for the next cursor
+func (p CursorPaginator) Next(req *http.Request, page Page) (*http.Request, error) {
+	value, err := jsonField(page.Body, p.Field)
+	if err != nil || value == nil {
+		return nil, err
+	}
+	// decode numbers as json.Number, so large numeric cursors keep their exact value
+	decoder := json.NewDecoder(bytes.NewReader(value))
+	decoder.UseNumber()
+	var cursor any
+	if err = decoder.Decode(&cursor); err != nil {
+		return nil, fmt.Errorf("cursor: %w", err)
+	}
+	var next string
+	switch c := cursor.(type) {
+	case string:
+		next = c
+	case json.Number:
+		next = c.String()
+	case nil:
+	default:
+		return nil, fmt.Errorf("cursor: unsupported type %T", cursor)
+	}
+	if next == "" {
+		return nil, nil
+	}
+	u := *req.URL
+	query := u.Query()
+	query.Set(orDefault(p.Param, "cursor"), next)
+	u.RawQuery = query.Encode()
+	return nextRequest(req, &u)
+}
+
+// OffsetPaginator pages through an API using offset and limit query parameters. If a page holds fewer items than the limit,
+// there are no more pages.
+type OffsetPaginator struct {
+	// OffsetParam is the query parameter for the offset. Default: offset
+	OffsetParam string
+	// LimitParam is the query parameter for the page size. Default: limit
+	LimitParam string
+	// Limit is the page size, used if the request doesn't set the LimitParam
+	Limit int
+	// ItemsField is the name of the JSON field holding the page's items. Use dots for nested fields. If empty, the page is a JSON array.
+	ItemsField string
+}
+
+var _ Paginator = OffsetPaginator{}
+
+// Next returns the request for the next offset
+func (p OffsetPaginator) Next(req *http.Request, page Page) (*http.Request, error) {
+	offsetParam, limitParam := orDefault(p.OffsetParam, "offset"), orDefault(p.LimitParam, "limit")
+	query := req.URL.Query()
+	limit := p.Limit
+	if value := query.Get(limitParam); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil {
+			return nil, fmt.Errorf("limit: %w", err)
+		}
+	}
+	if limit <= 0 {
+		return nil, errors.New("offset paginator: no limit")
+	}
+	offset, _ := strconv.Atoi(query.Get(offsetParam))
+
+	items, err := jsonField(page.Body, p.ItemsField)
+	if err != nil {
+		return nil, err
+	}
+	var list []json.RawMessage
+	if items != nil {
+		if err = json.Unmarshal(items, &list); err != nil {
+			return nil, fmt.Errorf("items: %w", err)
+		}
+	}
+	if len(list) < limit {
+		return nil, nil
+	}
+
+	u := *req.URL
+	query.Set(offsetParam, strconv.Itoa(offset+len(list)))
+	query.Set(limitParam, strconv.Itoa(limit))
+	u.RawQuery = query.Encode()
+	return nextRequest(req, &u)
+}
+
+// jsonField returns the (nested) field of a JSON object, or nil if it doesn't exist. If field is empty, it returns body.
+func jsonField(body []byte, field string) (json.RawMessage, error) {
+	value := json.RawMessage(bytes.TrimSpace(body))
+	if field == "" {
+		return value, nil
+	}
+	for _, name := range strings.Split(field, ".") {
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal(value, &object); err != nil {
+			return nil, fmt.Errorf("%s: %w", field, err)
+		}
+		var ok bool
+		if value, ok = object[name]; !ok {
+			return nil, nil
+		}
+	}
+	return value, nil
+}
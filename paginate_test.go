@@ -0,0 +1,242 @@
+package httpclient_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/clambin/httpclient"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+// pagedServer serves the items 0..9, in pages of 3
+func pagedServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	items := []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+	page := func(offset int) []int {
+		return items[min(offset, len(items)):min(offset+3, len(items))]
+	}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		offset, _ := strconv.Atoi(req.URL.Query().Get("offset"))
+		switch req.URL.Path {
+		case "/link":
+			if offset+3 < len(items) {
+				w.Header().Add("Link", `<https://example.com/first>; rel="first"`)
+				w.Header().Add("Link", fmt.Sprintf(`</link?offset=%d>; rel="next last"`, offset+3))
+			}
+			_ = json.NewEncoder(w).Encode(page(offset))
+		case "/cursor":
+			offset, _ = strconv.Atoi(req.URL.Query().Get("cursor"))
+			next := ""
+			if offset+3 < len(items) {
+				next = strconv.Itoa(offset + 3)
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{"items": page(offset), "meta": map[string]string{"next": next}})
+		case "/offset":
+			_ = json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"items": page(offset)}})
+		case "/loop":
+			w.Header().Set("Link", `</loop>; rel="next"`)
+			_ = json.NewEncoder(w).Encode(page(0))
+		default:
+			http.Error(w, "invalid endpoint", http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+type cursorPage struct {
+	Items []int `json:"items"`
+}
+
+type offsetPage struct {
+	Data cursorPage `json:"data"`
+}
+
+func TestPages(t *testing.T) {
+	s := pagedServer(t)
+	r := prometheus.NewRegistry()
+	metrics := httpclient.NewMetrics("foo", "")
+	r.MustRegister(metrics)
+	c := &httpclient.InstrumentedClient{Options: httpclient.Options{PrometheusMetrics: metrics}, Application: "foo"}
+
+	req, _ := http.NewRequest(http.MethodGet, s.URL+"/link", nil)
+	var items []int
+	for page, err := range httpclient.Pages(c, req, httpclient.LinkPaginator{}, 0) {
+		require.NoError(t, err)
+		var pageItems []int
+		require.NoError(t, json.Unmarshal(page.Body, &pageItems))
+		items = append(items, pageItems...)
+	}
+	assert.Equal(t, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, items)
+	// metrics are recorded per page
+	assert.Equal(t, map[string]uint64{"/link": 4}, getLatencyCounters(t, r, "foo_"))
+}
+
+func TestPagesJSON(t *testing.T) {
+	s := pagedServer(t)
+	c := &httpclient.BaseClient{}
+
+	req, _ := http.NewRequest(http.MethodGet, s.URL+"/cursor", nil)
+	var items []int
+	for page, err := range httpclient.PagesJSON[cursorPage](c, req, httpclient.CursorPaginator{Field: "meta.next"}, 0) {
+		require.NoError(t, err)
+		items = append(items, page.Items...)
+	}
+	assert.Equal(t, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, items)
+
+	// the iterator can be reused
+	pages := httpclient.PagesJSON[cursorPage](c, req, httpclient.CursorPaginator{Field: "meta.next"}, 2)
+	for range 2 {
+		var count int
+		for _, err := range pages {
+			require.NoError(t, err)
+			count++
+		}
+		assert.Equal(t, 2, count)
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, s.URL+"/offset?limit=3", nil)
+	items = nil
+	for page, err := range httpclient.PagesJSON[offsetPage](c, req, httpclient.OffsetPaginator{ItemsField: "data.items"}, 0) {
+		require.NoError(t, err)
+		items = append(items, page.Data.Items...)
+	}
+	assert.Equal(t, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, items)
+}
+
+func TestPages_MaxPages(t *testing.T) {
+	s := pagedServer(t)
+	req, _ := http.NewRequest(http.MethodGet, s.URL+"/offset", nil)
+	var count int
+	for _, err := range httpclient.PagesJSON[offsetPage](&httpclient.BaseClient{}, req, httpclient.OffsetPaginator{Limit: 3, ItemsField: "data.items"}, 2) {
+		require.NoError(t, err)
+		count++
+	}
+	assert.Equal(t, 2, count)
+
+	// a page that links to itself ends the iteration
+	req, _ = http.NewRequest(http.MethodGet, s.URL+"/loop", nil)
+	count = 0
+	for _, err := range httpclient.Pages(&httpclient.BaseClient{}, req, httpclient.LinkPaginator{}, 0) {
+		require.NoError(t, err)
+		count++
+	}
+	assert.Equal(t, 1, count)
+}
+
+func TestPages_Errors(t *testing.T) {
+	s := pagedServer(t)
+
+	req, _ := http.NewRequest(http.MethodGet, s.URL+"/invalid", nil)
+	var errs []error
+	for _, err := range httpclient.Pages(&httpclient.BaseClient{}, req, httpclient.LinkPaginator{}, 0) {
+		errs = append(errs, err)
+	}
+	require.Len(t, errs, 1)
+	assert.ErrorIs(t, errs[0], httpclient.ErrNotFound)
+
+	// offset paginator needs a limit
+	req, _ = http.NewRequest(http.MethodGet, s.URL+"/offset", nil)
+	errs = nil
+	for _, err := range httpclient.Pages(&httpclient.BaseClient{}, req, httpclient.OffsetPaginator{}, 0) {
+		errs = append(errs, err)
+	}
+	require.Len(t, errs, 2)
+	assert.NoError(t, errs[0])
+	assert.Error(t, errs[1])
+
+	// context cancellation stops the iteration
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ = http.NewRequestWithContext(ctx, http.MethodGet, s.URL+"/link", nil)
+	errs = nil
+	for _, err := range httpclient.Pages(&httpclient.BaseClient{}, req, httpclient.LinkPaginator{}, 0) {
+		errs = append(errs, err)
+		cancel()
+	}
+	require.Len(t, errs, 2)
+	assert.ErrorIs(t, errs[1], context.Canceled)
+
+	// pages exceeding the maximum body size
+	req, _ = http.NewRequest(http.MethodGet, s.URL+"/link", nil)
+	errs = nil
+	for _, err := range httpclient.Pages(&httpclient.BaseClient{}, req, httpclient.LinkPaginator{}, 0, httpclient.WithMaxBodySize(4)) {
+		errs = append(errs, err)
+	}
+	require.Len(t, errs, 1)
+	assert.ErrorIs(t, errs[0], httpclient.ErrBodyTooLarge)
+
+	// decoding errors
+	req, _ = http.NewRequest(http.MethodGet, s.URL+"/link", nil)
+	errs = nil
+	for _, err := range httpclient.PagesJSON[string](&httpclient.BaseClient{}, req, httpclient.LinkPaginator{}, 0) {
+		errs = append(errs, err)
+	}
+	require.Len(t, errs, 1)
+	assert.ErrorContains(t, errs[0], "decode:")
+}
+
+func TestCursorPaginator_Next(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		body string
+		want string
+	}{
+		{name: "string", body: `{"next":"abc"}`, want: "https://example.com/items?cursor=abc&limit=10"},
+		{name: "large number", body: `{"next":1234567890123456789}`, want: "https://example.com/items?cursor=1234567890123456789&limit=10"},
+		{name: "null", body: `{"next":null}`},
+		{name: "empty", body: `{"next":""}`},
+		{name: "missing", body: `{}`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, "https://example.com/items?limit=10", nil)
+			next, err := httpclient.CursorPaginator{Field: "next"}.Next(req, httpclient.Page{Body: []byte(tc.body)})
+			require.NoError(t, err)
+			if tc.want == "" {
+				assert.Nil(t, next)
+				return
+			}
+			require.NotNil(t, next)
+			assert.Equal(t, tc.want, next.URL.String())
+		})
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "https://example.com/items", nil)
+	_, err := httpclient.CursorPaginator{Field: "next"}.Next(req, httpclient.Page{Body: []byte(`{"next":true}`)})
+	assert.Error(t, err)
+}
+
+func TestLinkPaginator_Next(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		header []string
+		want   string
+	}{
+		{name: "next", header: []string{`<https://example.com/items?page=2>; rel="next"`}, want: "https://example.com/items?page=2"},
+		{name: "comma in url", header: []string{`<https://example.com/items?ids=1,2>; rel="prev", <https://example.com/items?ids=3,4>; rel="next"`}, want: "https://example.com/items?ids=3,4"},
+		{name: "semicolon in url", header: []string{`</items;v=2?page=2>; rel=next`}, want: "https://example.com/items;v=2?page=2"},
+		{name: "quoted comma", header: []string{`<https://example.com/a>; title="a, b"; rel="prev", </b>; rel="last next"`}, want: "https://example.com/b"},
+		{name: "multiple headers", header: []string{`</first>; rel="first"`, `</items?page=2>; rel="next"`}, want: "https://example.com/items?page=2"},
+		{name: "no next", header: []string{`</first>; rel="first"`}},
+		{name: "no rel", header: []string{`</items?page=2>`}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, "https://example.com/items", nil)
+			resp := &http.Response{Header: http.Header{"Link": tc.header}}
+			next, err := httpclient.LinkPaginator{}.Next(req, httpclient.Page{Response: resp})
+			require.NoError(t, err)
+			if tc.want == "" {
+				assert.Nil(t, next)
+				return
+			}
+			require.NotNil(t, next)
+			assert.Equal(t, tc.want, next.URL.String())
+		})
+	}
+}
@@ -0,0 +1,295 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"golang.org/x/time/rate"
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrRateLimitExceeded is the sentinel error for RateLimitError: errors.Is(err, ErrRateLimitExceeded) reports whether a request was rejected by a RateLimiter.
+var ErrRateLimitExceeded = errors.New("rate limit exceeded")
+
+// RateLimitError is returned by RateLimiter when no token is available for a request.
+type RateLimitError struct {
+	// Key identifies the limit that was exceeded: "global", the request's host or the matching RateLimitEntry's Endpoint
+	Key string
+	// Delay is the time until a token becomes available
+	Delay time.Duration
+}
+
+// Error returns the error message
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limit exceeded for %s: retry after %s", e.Key, e.Delay)
+}
+
+// Unwrap returns ErrRateLimitExceeded
+func (e *RateLimitError) Unwrap() error {
+	return ErrRateLimitExceeded
+}
+
+// RateLimit defines a token bucket: Requests tokens are added every Per, up to a maximum of Burst tokens.
+type RateLimit struct {
+	Requests int
+	Per      time.Duration
+	// Burst is the maximum number of requests that can be sent at once. Default: 1
+	Burst int
+}
+
+func (l RateLimit) newLimiter() *rate.Limiter {
+	burst := l.Burst
+	if burst <= 0 {
+		burst = 1
+	}
+	return rate.NewLimiter(l.limit(), burst)
+}
+
+func (l RateLimit) limit() rate.Limit {
+	if l.Requests <= 0 || l.Per <= 0 {
+		return rate.Inf
+	}
+	return rate.Limit(float64(l.Requests) / l.Per.Seconds())
+}
+
+// RateLimitEntry applies a RateLimit to requests matching its Endpoint and Methods. The matching rules are the same as for CacheTableEntry.
+// All requests matching the entry share the same token bucket.
+type RateLimitEntry struct {
+	// Endpoint is the URL Path of the requests to limit. Can be a literal path, or a regular expression. In the latter case, set IsRegExp to true
+	Endpoint string
+	// Methods is the list of HTTP Methods to limit. If empty, requests for any method are limited.
+	Methods []string
+	// IsRegExp indicated the Endpoint is a regular expression.
+	// Note: RateLimiter will panic if Endpoint does not contain a valid regular expression.
+	IsRegExp bool
+	// Limit is the rate limit for the endpoint
+	Limit          RateLimit
+	compiledRegExp *regexp.Regexp
+	limiter        *rate.Limiter
+}
+
+// RateLimiter implements the Caller interface. It limits the rate of outgoing requests using token buckets.
+// A request needs a token from each applicable bucket: the Global bucket, the bucket of the request's host and the bucket
+// of the first matching entry in Endpoints.
+//
+// If no token is available, RateLimiter either waits until one becomes available (if Wait is set), or returns a RateLimitError.
+// When waiting, RateLimiter returns a RateLimitError straight away if the wait would exceed the request's context deadline.
+//
+// If Adaptive is set, RateLimiter also adapts the host's bucket to the upstream's limits: if a response has
+// X-RateLimit-Remaining set to zero, requests to that host are held back until the time indicated by X-RateLimit-Reset.
+// If there are remaining requests, the host's rate is lowered to spread them evenly until the reset.
+// A 429 response holds back requests to that host for the period indicated by its Retry-After header.
+type RateLimiter struct {
+	Caller
+	// Global limits all requests. Optional.
+	Global *RateLimit
+	// PerHost limits the requests to each host. Optional.
+	PerHost *RateLimit
+	// Endpoints limits requests to specific endpoints. Optional.
+	Endpoints []RateLimitEntry
+	// Wait makes RateLimiter wait for a token, rather than returning a RateLimitError.
+	Wait bool
+	// Adaptive adapts the rate of each host based on the rate limit headers in its responses.
+	Adaptive bool
+
+	lock     sync.Mutex
+	compiled bool
+	global   *bucket
+	hosts    map[string]*bucket
+}
+
+var _ Caller = &RateLimiter{}
+
+// Do sends the request once a token is available
+func (l *RateLimiter) Do(req *http.Request) (*http.Response, error) {
+	buckets := l.buckets(req)
+	if err := l.acquire(req.Context(), buckets); err != nil {
+		return nil, err
+	}
+
+	resp, err := l.Caller.Do(req)
+
+	if err == nil && l.Adaptive {
+		l.hostBucket(req.URL.Host).adapt(resp)
+	}
+	return resp, err
+}
+
+func (l *RateLimiter) acquire(ctx context.Context, buckets []*bucket) error {
+	now := time.Now()
+	reservations := make([]*rate.Reservation, 0, len(buckets))
+	cancel := func() {
+		for _, r := range reservations {
+			r.CancelAt(now)
+		}
+	}
+
+	var delay time.Duration
+	var key string
+	for _, b := range buckets {
+		r, blockedFor := b.reserve(now)
+		if r == nil {
+			cancel()
+			return &RateLimitError{Key: b.key}
+		}
+		reservations = append(reservations, r)
+		if d := max(r.DelayFrom(now), blockedFor); d > delay {
+			delay, key = d, b.key
+		}
+	}
+
+	if delay == 0 {
+		return nil
+	}
+	if deadline, ok := ctx.Deadline(); !l.Wait || (ok && deadline.Sub(now) < delay) {
+		cancel()
+		return &RateLimitError{Key: key, Delay: delay}
+	}
+	if err := sleep(ctx, delay); err != nil {
+		cancel()
+		return err
+	}
+	return nil
+}
+
+// buckets returns the buckets that apply to the request
+func (l *RateLimiter) buckets(req *http.Request) []*bucket {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.compileIfNeeded()
+
+	var buckets []*bucket
+	if l.global != nil {
+		buckets = append(buckets, l.global)
+	}
+	if l.PerHost != nil || l.Adaptive {
+		buckets = append(buckets, l.hostBucketLocked(req.URL.Host))
+	}
+	for index := range l.Endpoints {
+		entry := &l.Endpoints[index]
+		if matchesEndpoint(req, entry.Endpoint, entry.IsRegExp, entry.compiledRegExp) && matchesMethods(req, entry.Methods) {
+			buckets = append(buckets, &bucket{key: entry.Endpoint, limiter: entry.limiter})
+			break
+		}
+	}
+	return buckets
+}
+
+func (l *RateLimiter) hostBucket(host string) *bucket {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.hostBucketLocked(host)
+}
+
+func (l *RateLimiter) hostBucketLocked(host string) *bucket {
+	if l.hosts == nil {
+		l.hosts = make(map[string]*bucket)
+	}
+	b, ok := l.hosts[host]
+	if !ok {
+		limit := RateLimit{}
+		if l.PerHost != nil {
+			limit = *l.PerHost
+		}
+		b = &bucket{key: host, limiter: limit.newLimiter(), limit: limit.limit()}
+		l.hosts[host] = b
+	}
+	return b
+}
+
+func (l *RateLimiter) compileIfNeeded() {
+	if l.compiled {
+		return
+	}
+	if l.Global != nil {
+		l.global = &bucket{key: "global", limiter: l.Global.newLimiter()}
+	}
+	for index := range l.Endpoints {
+		entry := &l.Endpoints[index]
+		if entry.IsRegExp {
+			var err error
+			if entry.compiledRegExp, err = regexp.Compile(entry.Endpoint); err != nil {
+				panic(fmt.Errorf("rateLimiter: invalid regexp '%s': %w", entry.Endpoint, err))
+			}
+		}
+		entry.limiter = entry.Limit.newLimiter()
+	}
+	l.compiled = true
+}
+
+// bucket is a token bucket, which can be held back until a point in time (as instructed by the upstream)
+type bucket struct {
+	key          string
+	limiter      *rate.Limiter
+	limit        rate.Limit // configured limit
+	lock         sync.Mutex
+	blockedUntil time.Time
+}
+
+// reserve reserves a token. It returns nil if the bucket can never provide a token.
+// blockedFor is the remaining time the bucket is being held back.
+func (b *bucket) reserve(now time.Time) (r *rate.Reservation, blockedFor time.Duration) {
+	b.lock.Lock()
+	blockedFor = max(b.blockedUntil.Sub(now), 0)
+	b.lock.Unlock()
+
+	r = b.limiter.ReserveN(now, 1)
+	if !r.OK() {
+		return nil, 0
+	}
+	return r, blockedFor
+}
+
+func (b *bucket) adapt(resp *http.Response) {
+	now := time.Now()
+	if resp.StatusCode == http.StatusTooManyRequests {
+		if retryAfter, ok := parseRetryAfter(resp); ok {
+			b.block(now.Add(retryAfter))
+		}
+		return
+	}
+
+	remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return
+	}
+	reset, ok := parseRateLimitReset(resp.Header.Get("X-RateLimit-Reset"), now)
+	if !ok {
+		return
+	}
+	if remaining <= 0 {
+		b.block(reset)
+		return
+	}
+	limit := rate.Limit(float64(remaining) / reset.Sub(now).Seconds())
+	if reset.Sub(now) <= 0 || limit > b.limit {
+		limit = b.limit
+	}
+	b.limiter.SetLimitAt(now, limit)
+}
+
+func (b *bucket) block(until time.Time) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if until.After(b.blockedUntil) {
+		b.blockedUntil = until
+	}
+}
+
+// parseRateLimitReset parses the X-RateLimit-Reset header. Depending on the upstream, it contains either a Unix timestamp
+// or the number of seconds until the reset.
+func parseRateLimitReset(value string, now time.Time) (time.Time, bool) {
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seconds < 0 {
+		return time.Time{}, false
+	}
+	if seconds > now.Unix()/2 {
+		return time.Unix(seconds, 0), true
+	}
+	return now.Add(time.Duration(seconds) * time.Second), true
+}
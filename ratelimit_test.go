@@ -0,0 +1,149 @@
+package httpclient_test
+
+import (
+	"context"
+	"github.com/clambin/httpclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter_Do(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	c := &httpclient.RateLimiter{
+		Caller:  &httpclient.BaseClient{},
+		PerHost: &httpclient.RateLimit{Requests: 1, Per: time.Hour},
+		Endpoints: []httpclient.RateLimitEntry{
+			{Endpoint: "/foo", Limit: httpclient.RateLimit{Requests: 1, Per: time.Minute, Burst: 2}},
+		},
+	}
+
+	_, err := doCall(c, s.URL+"/foo")
+	require.NoError(t, err)
+
+	_, err = doCall(c, s.URL+"/foo")
+	require.ErrorIs(t, err, httpclient.ErrRateLimitExceeded)
+	var rateLimitErr *httpclient.RateLimitError
+	require.ErrorAs(t, err, &rateLimitErr)
+	assert.Equal(t, s.Listener.Addr().String(), rateLimitErr.Key)
+	assert.Greater(t, rateLimitErr.Delay, 59*time.Minute)
+}
+
+func TestRateLimiter_Do_Endpoints(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	c := &httpclient.RateLimiter{
+		Caller: &httpclient.BaseClient{},
+		Endpoints: []httpclient.RateLimitEntry{
+			{Endpoint: "/fo+", IsRegExp: true, Methods: []string{http.MethodGet}, Limit: httpclient.RateLimit{Requests: 1, Per: time.Hour}},
+		},
+	}
+
+	_, err := doCall(c, s.URL+"/foo")
+	require.NoError(t, err)
+	_, err = doCall(c, s.URL+"/foo")
+	require.ErrorIs(t, err, httpclient.ErrRateLimitExceeded)
+
+	for i := 0; i < 3; i++ {
+		_, err = doCall(c, s.URL+"/bar")
+		require.Error(t, err)
+		assert.NotErrorIs(t, err, httpclient.ErrRateLimitExceeded)
+	}
+}
+
+func TestRateLimiter_Do_Wait(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	c := &httpclient.RateLimiter{
+		Caller: &httpclient.BaseClient{},
+		Global: &httpclient.RateLimit{Requests: 10, Per: time.Second},
+		Wait:   true,
+	}
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		_, err := doCall(c, s.URL+"/foo")
+		require.NoError(t, err)
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 150*time.Millisecond)
+
+	// wait would exceed the context's deadline
+	c = &httpclient.RateLimiter{
+		Caller: &httpclient.BaseClient{},
+		Global: &httpclient.RateLimit{Requests: 1, Per: time.Hour},
+		Wait:   true,
+	}
+	_, err := doCall(c, s.URL+"/foo")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, s.URL+"/foo", nil)
+	start = time.Now()
+	_, err = c.Do(req)
+	require.ErrorIs(t, err, httpclient.ErrRateLimitExceeded)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+}
+
+func TestRateLimiter_Do_Adaptive(t *testing.T) {
+	var remaining, reset, retryAfter string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if retryAfter != "" {
+			w.Header().Set("Retry-After", retryAfter)
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Header().Set("X-RateLimit-Remaining", remaining)
+		w.Header().Set("X-RateLimit-Reset", reset)
+		handler(w, req)
+	}))
+	defer s.Close()
+
+	c := &httpclient.RateLimiter{Caller: &httpclient.BaseClient{}, Adaptive: true}
+
+	// remaining requests are spread evenly until the reset
+	remaining, reset = "10", "60"
+	_, err := doCall(c, s.URL+"/foo")
+	require.NoError(t, err)
+	// the bucket still holds its burst
+	_, err = doCall(c, s.URL+"/foo")
+	require.NoError(t, err)
+	_, err = doCall(c, s.URL+"/foo")
+	var rateLimitErr *httpclient.RateLimitError
+	require.ErrorAs(t, err, &rateLimitErr)
+	assert.Greater(t, rateLimitErr.Delay, 5*time.Second)
+
+	// no remaining requests: wait until the reset
+	c = &httpclient.RateLimiter{Caller: &httpclient.BaseClient{}, Adaptive: true}
+	remaining, reset = "0", "60"
+	_, err = doCall(c, s.URL+"/foo")
+	require.NoError(t, err)
+	_, err = doCall(c, s.URL+"/foo")
+	require.ErrorAs(t, err, &rateLimitErr)
+	assert.Greater(t, rateLimitErr.Delay, 50*time.Second)
+
+	c = &httpclient.RateLimiter{Caller: &httpclient.BaseClient{}, Adaptive: true}
+	retryAfter = "30"
+	_, err = doCall(c, s.URL+"/foo")
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, httpclient.ErrRateLimitExceeded)
+	_, err = doCall(c, s.URL+"/foo")
+	require.ErrorAs(t, err, &rateLimitErr)
+	assert.Greater(t, rateLimitErr.Delay, 20*time.Second)
+}
+
+func TestRateLimiter_Invalid_Input(t *testing.T) {
+	c := &httpclient.RateLimiter{
+		Caller:    &httpclient.BaseClient{},
+		Endpoints: []httpclient.RateLimitEntry{{Endpoint: `/foo/[\d+`, IsRegExp: true}},
+	}
+	req, _ := http.NewRequest(http.MethodGet, "http://localhost/foo", nil)
+	assert.Panics(t, func() { _, _ = c.Do(req) })
+}
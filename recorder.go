@@ -0,0 +1,26 @@
+package httpclient
+
+import (
+	"context"
+	"time"
+)
+
+// MetricsRecorder records performance metrics of the API calls performed by InstrumentedClient.
+// Metrics implements MetricsRecorder for Prometheus. OTelMetrics implements it for OpenTelemetry.
+type MetricsRecorder interface {
+	// ReportRequest is called when the response headers have been received, or when the request failed.
+	ReportRequest(ctx context.Context, call CallInfo, duration time.Duration, err error)
+	// ReportTransfer is called when the response body has been read completely, or is closed.
+	// duration is measured from the start of the request. err is set when reading the body failed.
+	ReportTransfer(ctx context.Context, call CallInfo, duration time.Duration, err error)
+}
+
+// CallInfo describes an API call reported to a MetricsRecorder.
+type CallInfo struct {
+	Application   string // application issuing the request
+	Endpoint      string // endpoint of the request, as determined by the EndpointNamer
+	Method        string // request's method
+	ServerAddress string // host of the request's URL
+	ServerPort    int    // port of the request's URL
+	StatusCode    int    // status code of the response. Zero if the request failed
+}
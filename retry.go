@@ -0,0 +1,236 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// Retrier implements the Caller interface. It retries failed requests, using exponential backoff with full jitter.
+//
+// Before each retry, the request's body is rewound using the request's GetBody. Requests with a body, but without GetBody,
+// are not retried. If the response contains a Retry-After header, Retrier waits at least that long before retrying.
+// If Retry-After exceeds MaxBackoff, or the wait would exceed the request's context deadline, the last response is returned instead.
+type Retrier struct {
+	Caller
+	// MaxAttempts is the maximum number of attempts, including the first one. Default: 3
+	MaxAttempts int
+	// InitialBackoff is the maximum wait before the first retry. It doubles with each retry. Default: 100ms
+	InitialBackoff time.Duration
+	// MaxBackoff is the maximum wait between two attempts. Default: 10s
+	MaxBackoff time.Duration
+	// ShouldRetry determines if a request should be retried. If nil, DefaultRetryPolicy is used.
+	ShouldRetry func(req *http.Request, resp *http.Response, err error) bool
+	// Metrics records each attempt. Optional.
+	Metrics *RetryMetrics
+	// Application is used as the application label in Metrics.
+	Application string
+}
+
+var _ Caller = &Retrier{}
+
+const (
+	defaultMaxAttempts    = 3
+	defaultInitialBackoff = 100 * time.Millisecond
+	defaultMaxBackoff     = 10 * time.Second
+)
+
+// Do sends the request, retrying it as needed.
+func (r *Retrier) Do(req *http.Request) (resp *http.Response, err error) {
+	ctx := req.Context()
+	attemptReq := req
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 {
+			if attemptReq, err = rewind(req); err != nil {
+				return nil, err
+			}
+		}
+
+		resp, err = r.Caller.Do(attemptReq)
+		r.Metrics.reportAttempt(r.Application, req, attempt)
+
+		if attempt >= r.maxAttempts() || !canRewind(req) || !r.shouldRetry(req, resp, err) {
+			return resp, err
+		}
+
+		delay := r.backoff(attempt)
+		if retryAfter, ok := parseRetryAfter(resp); ok {
+			if retryAfter > r.maxBackoff() {
+				return resp, err
+			}
+			delay = max(delay, retryAfter)
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return resp, err
+		}
+
+		drainAndClose(resp)
+		if err = sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (r *Retrier) maxAttempts() int {
+	if r.MaxAttempts <= 0 {
+		return defaultMaxAttempts
+	}
+	return r.MaxAttempts
+}
+
+func (r *Retrier) maxBackoff() time.Duration {
+	if r.MaxBackoff <= 0 {
+		return defaultMaxBackoff
+	}
+	return r.MaxBackoff
+}
+
+func (r *Retrier) shouldRetry(req *http.Request, resp *http.Response, err error) bool {
+	if r.ShouldRetry == nil {
+		return DefaultRetryPolicy(req, resp, err)
+	}
+	return r.ShouldRetry(req, resp, err)
+}
+
+// backoff returns a random delay between zero and InitialBackoff * 2^(attempt-1), capped at MaxBackoff (i.e. "full jitter").
+func (r *Retrier) backoff(attempt int) time.Duration {
+	ceiling := r.InitialBackoff
+	if ceiling <= 0 {
+		ceiling = defaultInitialBackoff
+	}
+	for i := 1; i < attempt && ceiling < r.maxBackoff(); i++ {
+		ceiling *= 2
+	}
+	ceiling = min(ceiling, r.maxBackoff())
+	return time.Duration(rand.Int64N(int64(ceiling) + 1))
+}
+
+// DefaultRetryPolicy retries idempotent requests that failed due to a connection error,
+// or that received a 429, 502, 503 or 504 response.
+func DefaultRetryPolicy(req *http.Request, resp *http.Response, err error) bool {
+	if !isIdempotent(req) {
+		return false
+	}
+	if err != nil {
+		return isConnectionError(err)
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != ""
+}
+
+func isConnectionError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED)
+}
+
+func canRewind(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+func rewind(req *http.Request) (*http.Request, error) {
+	clone := req.Clone(req.Context())
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		if clone.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	return clone, nil
+}
+
+// parseRetryAfter returns the delay indicated by the response's Retry-After header, either in seconds or as an HTTP date.
+func parseRetryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0), true
+	}
+	return 0, false
+}
+
+// drainAndClose reads (a limited amount of) the remaining response body and closes it, so the connection can be reused.
+func drainAndClose(resp *http.Response) {
+	if resp == nil || resp.Body == nil {
+		return
+	}
+	_, _ = io.CopyN(io.Discard, resp.Body, 64<<10)
+	_ = resp.Body.Close()
+}
+
+func sleep(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// RetryMetrics contains Prometheus metrics recorded by Retrier.
+type RetryMetrics struct {
+	attempts *prometheus.CounterVec // counts the attempts per request
+}
+
+// NewRetryMetrics creates the Prometheus metrics recorded by Retrier.
+func NewRetryMetrics(namespace, subsystem string) *RetryMetrics {
+	return &RetryMetrics{
+		attempts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: prometheus.BuildFQName(namespace, subsystem, "api_attempts_total"),
+			Help: "Number of attempts of API calls, by attempt number",
+		}, []string{"application", "host", "method", "attempt"}),
+	}
+}
+
+var _ prometheus.Collector = &RetryMetrics{}
+
+// Describe implements the prometheus.Collector interface so clients can register RetryMetrics as a whole
+func (m *RetryMetrics) Describe(ch chan<- *prometheus.Desc) {
+	m.attempts.Describe(ch)
+}
+
+// Collect implements the prometheus.Collector interface so clients can register RetryMetrics as a whole
+func (m *RetryMetrics) Collect(ch chan<- prometheus.Metric) {
+	m.attempts.Collect(ch)
+}
+
+func (m *RetryMetrics) reportAttempt(application string, req *http.Request, attempt int) {
+	if m == nil {
+		return
+	}
+	m.attempts.WithLabelValues(application, req.URL.Host, req.Method, strconv.Itoa(attempt)).Inc()
+}
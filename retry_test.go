@@ -0,0 +1,170 @@
+package httpclient_test
+
+import (
+	"context"
+	"github.com/clambin/httpclient"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetrier_Do(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		method     string
+		body       io.Reader
+		failures   int
+		status     int
+		wantStatus int
+		wantCalls  int32
+	}{
+		{name: "success", method: http.MethodGet, wantStatus: http.StatusOK, wantCalls: 1},
+		{name: "recovers", method: http.MethodGet, failures: 2, status: http.StatusServiceUnavailable, wantStatus: http.StatusOK, wantCalls: 3},
+		{name: "gives up", method: http.MethodGet, failures: 5, status: http.StatusBadGateway, wantStatus: http.StatusBadGateway, wantCalls: 3},
+		{name: "not retryable", method: http.MethodGet, failures: 1, status: http.StatusInternalServerError, wantStatus: http.StatusInternalServerError, wantCalls: 1},
+		{name: "not idempotent", method: http.MethodPost, failures: 1, status: http.StatusServiceUnavailable, wantStatus: http.StatusServiceUnavailable, wantCalls: 1},
+		{name: "rewinds body", method: http.MethodPut, body: strings.NewReader("hello"), failures: 1, status: http.StatusTooManyRequests, wantStatus: http.StatusOK, wantCalls: 2},
+		{name: "no GetBody", method: http.MethodPut, body: io.NopCloser(strings.NewReader("hello")), failures: 1, status: http.StatusTooManyRequests, wantStatus: http.StatusTooManyRequests, wantCalls: 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var calls atomic.Int32
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				call := calls.Add(1)
+				if body, _ := io.ReadAll(req.Body); tc.body != nil && string(body) != "hello" {
+					http.Error(w, "bad body: "+string(body), http.StatusBadRequest)
+					return
+				}
+				if int(call) <= tc.failures {
+					w.WriteHeader(tc.status)
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer s.Close()
+
+			r := prometheus.NewRegistry()
+			metrics := httpclient.NewRetryMetrics("foo", "")
+			r.MustRegister(metrics)
+			c := &httpclient.Retrier{
+				Caller:         &httpclient.BaseClient{},
+				InitialBackoff: time.Millisecond,
+				Metrics:        metrics,
+				Application:    "foo",
+			}
+
+			req, _ := http.NewRequest(tc.method, s.URL, tc.body)
+			resp, err := c.Do(req)
+			require.NoError(t, err)
+			_ = resp.Body.Close()
+			assert.Equal(t, tc.wantStatus, resp.StatusCode)
+			assert.Equal(t, tc.wantCalls, calls.Load())
+			attempts := getAttempts(t, r)
+			assert.Len(t, attempts, int(tc.wantCalls))
+			assert.Equal(t, float64(1), attempts["1"])
+		})
+	}
+}
+
+func TestRetrier_Do_ConnectionError(t *testing.T) {
+	var attempts int
+	c := &httpclient.Retrier{
+		Caller: httpclient.CallerFunc(func(req *http.Request) (*http.Response, error) {
+			attempts++
+			return (&httpclient.BaseClient{}).Do(req)
+		}),
+		MaxAttempts:    2,
+		InitialBackoff: time.Millisecond,
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "http://localhost:0", nil)
+	_, err := c.Do(req)
+	require.Error(t, err)
+	assert.Equal(t, 2, attempts)
+}
+
+func TestRetrier_Do_RetryAfter(t *testing.T) {
+	var calls atomic.Int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if calls.Add(1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer s.Close()
+
+	c := &httpclient.Retrier{Caller: &httpclient.BaseClient{}, InitialBackoff: time.Millisecond}
+
+	start := time.Now()
+	req, _ := http.NewRequest(http.MethodGet, s.URL, nil)
+	resp, err := c.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.GreaterOrEqual(t, time.Since(start), time.Second)
+
+	// Retry-After exceeds the context's deadline: return the response
+	calls.Store(0)
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	req, _ = http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+	resp, err = c.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, int32(1), calls.Load())
+
+	// Retry-After exceeds MaxBackoff: return the response
+	calls.Store(0)
+	c.MaxBackoff = 100 * time.Millisecond
+	req, _ = http.NewRequest(http.MethodGet, s.URL, nil)
+	resp, err = c.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+}
+
+func TestRetrier_Do_Cancelled(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer s.Close()
+
+	c := &httpclient.Retrier{Caller: &httpclient.BaseClient{}, InitialBackoff: time.Hour, MaxBackoff: time.Hour}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+	}()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+	_, err := c.Do(req)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func getAttempts(t *testing.T, g prometheus.Gatherer) map[string]float64 {
+	t.Helper()
+	attempts := make(map[string]float64)
+	m, err := g.Gather()
+	require.NoError(t, err)
+	for _, entry := range m {
+		if entry.GetName() == "foo_api_attempts_total" {
+			for _, metric := range entry.Metric {
+				for _, label := range metric.Label {
+					if label.GetName() == "attempt" {
+						attempts[label.GetValue()] = metric.Counter.GetValue()
+					}
+				}
+			}
+		}
+	}
+	return attempts
+}
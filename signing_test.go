@@ -0,0 +1,148 @@
+package httpclient_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/clambin/httpclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSigV4Signer_Sign(t *testing.T) {
+	// test vectors from the AWS Signature Version 4 test suite
+	for _, tc := range []struct {
+		name      string
+		method    string
+		url       string
+		signature string
+	}{
+		{
+			name:      "get-vanilla",
+			method:    http.MethodGet,
+			url:       "https://example.amazonaws.com/",
+			signature: "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		},
+		{
+			name:      "get-vanilla-query-order-key-case",
+			method:    http.MethodGet,
+			url:       "https://example.amazonaws.com/?Param2=value2&Param1=value1",
+			signature: "b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500",
+		},
+		{
+			name:      "get-vanilla-query-order-value",
+			method:    http.MethodGet,
+			url:       "https://example.amazonaws.com/?Param1=value2&Param1=value1",
+			signature: "5772eed61e12b33fae39ee5e7012498b51d56abc0abb7c60486157bd471c4694",
+		},
+		{
+			name:      "get-query-name-prefix",
+			method:    http.MethodGet,
+			url:       "https://example.amazonaws.com/?a.b=1&a=z",
+			signature: sigV4Signature(t, "GET\n/\na=z&a.b=1\nhost:example.amazonaws.com\nx-amz-date:20150830T123600Z\n\nhost;x-amz-date\n"+emptyHash),
+		},
+		{
+			name:      "post-vanilla",
+			method:    http.MethodPost,
+			url:       "https://example.amazonaws.com/",
+			signature: "5da7c1a2acd57cee7505fc6676e4e544621c30862966e37dddb68e92efbe5d6b",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := httpclient.SigV4Signer{
+				AccessKeyID:     "AKIDEXAMPLE",
+				SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+				Region:          "us-east-1",
+				Service:         "service",
+				Now:             func() time.Time { return time.Date(2015, time.August, 30, 12, 36, 0, 0, time.UTC) },
+			}
+			req, _ := http.NewRequest(tc.method, tc.url, nil)
+			require.NoError(t, s.Sign(req))
+			assert.Equal(t, "20150830T123600Z", req.Header.Get("X-Amz-Date"))
+			assert.Empty(t, req.Header.Get("X-Amz-Content-Sha256"))
+			assert.Equal(t, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature="+tc.signature, req.Header.Get("Authorization"))
+		})
+	}
+}
+
+const emptyHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// sigV4Signature computes the signature of a canonical request for the test suite's credentials, region, service and time
+func sigV4Signature(t *testing.T, canonicalRequest string) string {
+	t.Helper()
+	mac := func(key []byte, data string) []byte {
+		h := hmac.New(sha256.New, key)
+		_, _ = h.Write([]byte(data))
+		return h.Sum(nil)
+	}
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n20150830T123600Z\n20150830/us-east-1/service/aws4_request\n" + hex.EncodeToString(hash[:])
+	key := mac([]byte("AWS4wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"), "20150830")
+	for _, part := range []string{"us-east-1", "service", "aws4_request"} {
+		key = mac(key, part)
+	}
+	return hex.EncodeToString(mac(key, stringToSign))
+}
+
+func TestSigV4Signer_Sign_S3(t *testing.T) {
+	s := httpclient.SigV4Signer{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "secret",
+		SessionToken:    "session",
+		Region:          "us-east-1",
+		Service:         "s3",
+	}
+	req, _ := http.NewRequest(http.MethodPut, "https://bucket.s3.amazonaws.com/foo/bar baz", strings.NewReader("hello"))
+	require.NoError(t, s.Sign(req))
+	assert.Equal(t, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", req.Header.Get("X-Amz-Content-Sha256"))
+	assert.Equal(t, "session", req.Header.Get("X-Amz-Security-Token"))
+	assert.Contains(t, req.Header.Get("Authorization"), "SignedHeaders=host;x-amz-content-sha256;x-amz-date;x-amz-security-token,")
+
+	// body can still be read
+	body, err := io.ReadAll(req.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(body))
+
+	s.UnsignedPayload = true
+	req, _ = http.NewRequest(http.MethodPut, "https://bucket.s3.amazonaws.com/foo", strings.NewReader("hello"))
+	require.NoError(t, s.Sign(req))
+	assert.Equal(t, "UNSIGNED-PAYLOAD", req.Header.Get("X-Amz-Content-Sha256"))
+}
+
+func TestHMACSigner_Sign(t *testing.T) {
+	s := httpclient.HMACSigner{
+		Secret:  []byte("secret"),
+		Headers: []string{"Host", "Content-Type"},
+		Now:     func() time.Time { return time.Unix(1700000000, 0) },
+	}
+	req, _ := http.NewRequest(http.MethodPost, "https://example.com/foo?b=2&a=1", strings.NewReader("hello"))
+	req.Header.Set("Content-Type", "application/json")
+	require.NoError(t, s.Sign(req))
+
+	h := hmac.New(sha256.New, []byte("secret"))
+	_, _ = h.Write([]byte("POST\n/foo\na=1&b=2\nhost:example.com\ncontent-type:application/json\n1700000000\n" +
+		"2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"))
+	assert.Equal(t, "1700000000", req.Header.Get("X-Signature-Timestamp"))
+	assert.Equal(t, hex.EncodeToString(h.Sum(nil)), req.Header.Get("X-Signature"))
+}
+
+func TestSigningClient_Do(t *testing.T) {
+	var received *http.Request
+	c := httpclient.SigningClient{
+		Caller: httpclient.CallerFunc(func(req *http.Request) (*http.Response, error) {
+			received = req
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+		}),
+		Signer: &httpclient.HMACSigner{Secret: []byte("secret"), SignatureHeader: "X-Sig"},
+	}
+	req, _ := http.NewRequest(http.MethodGet, "https://example.com/", nil)
+	_, err := c.Do(req)
+	require.NoError(t, err)
+	assert.NotEmpty(t, received.Header.Get("X-Sig"))
+	assert.Empty(t, req.Header.Get("X-Sig"))
+}
@@ -0,0 +1,284 @@
+package httpclient
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"io"
+	"iter"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Event is a single Server-Sent Event
+type Event struct {
+	// ID is the event's id. It is sent as Last-Event-ID when reconnecting
+	ID string
+	// Event is the event type. Default: message
+	Event string
+	// Data is the event's data. Multiple data lines are joined with a newline
+	Data string
+}
+
+// EventSource implements a Server-Sent Events client. It connects to URL and reconnects when the stream ends or fails,
+// sending the id of the last received event in the Last-Event-ID header.
+//
+// Before reconnecting, EventSource waits for the delay set by the server's "retry" field. If the server didn't set one, it uses
+// exponential backoff with full jitter. Connections that fail, or return a 5xx status code, count as consecutive failures.
+// A 204 No Content response ends the stream. Any other response that is not a 200 text/event-stream fails the stream.
+type EventSource struct {
+	Caller
+	// URL is the URL of the event stream
+	URL string
+	// Header holds any additional headers to send
+	Header http.Header
+	// InitialBackoff is the maximum wait before the first reconnect. It doubles with each consecutive failure. Default: 1s
+	InitialBackoff time.Duration
+	// MaxBackoff is the maximum wait between two connection attempts. Default: 30s
+	MaxBackoff time.Duration
+	// MaxFailures is the maximum number of consecutive failed connection attempts, before the stream fails. Zero means no limit.
+	MaxFailures int
+	// Metrics records the received events and reconnects. Optional.
+	Metrics *StreamMetrics
+	// Application is used as the application label in Metrics.
+	Application string
+}
+
+const (
+	defaultStreamInitialBackoff = time.Second
+	defaultStreamMaxBackoff     = 30 * time.Second
+)
+
+// Events returns an iterator over the received events. The iterator only yields an error if the stream fails, or ctx is done,
+// and stops after yielding it.
+func (s *EventSource) Events(ctx context.Context) iter.Seq2[Event, error] {
+	return func(yield func(Event, error) bool) {
+		var stream eventStream
+		var failures int
+		for {
+			done, err := s.connect(ctx, &stream, yield)
+			switch {
+			case done:
+				return
+			case ctx.Err() != nil:
+				yield(Event{}, ctx.Err())
+				return
+			case err != nil:
+				failures++
+				if s.MaxFailures > 0 && failures >= s.MaxFailures {
+					yield(Event{}, err)
+					return
+				}
+			default:
+				failures = 0
+			}
+
+			delay := stream.retry
+			if delay == 0 {
+				delay = s.backoff(failures)
+			}
+			if err = sleep(ctx, delay); err != nil {
+				yield(Event{}, err)
+				return
+			}
+			s.Metrics.reportReconnect(s.Application, s.URL)
+		}
+	}
+}
+
+// connect opens the stream and yields its events, until the stream ends. done indicates the iteration is finished.
+// err indicates the connection failed and should be counted as a failure.
+func (s *EventSource) connect(ctx context.Context, stream *eventStream, yield func(Event, error) bool) (done bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+	if err != nil {
+		yield(Event{}, err)
+		return true, err
+	}
+	for name, values := range s.Header {
+		req.Header[http.CanonicalHeaderKey(name)] = values
+	}
+	req.Header.Set("Accept", eventStreamContentType)
+	req.Header.Set("Cache-Control", "no-cache")
+	if stream.lastEventID != "" {
+		req.Header.Set("Last-Event-ID", stream.lastEventID)
+	}
+
+	resp, err := s.Caller.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	switch {
+	case resp.StatusCode == http.StatusNoContent:
+		return true, nil
+	case resp.StatusCode >= 500:
+		return false, NewHTTPError(req, resp)
+	case resp.StatusCode != http.StatusOK:
+		yield(Event{}, NewHTTPError(req, resp))
+		return true, nil
+	case !IsEventStream(resp.Header):
+		yield(Event{}, errors.New("sse: unexpected content type "+resp.Header.Get("Content-Type")))
+		return true, nil
+	}
+
+	for event, err := range stream.read(resp.Body) {
+		if err != nil {
+			// the stream ended
+			return false, nil
+		}
+		s.Metrics.reportEvent(s.Application, s.URL, event.Event)
+		if !yield(event, nil) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *EventSource) backoff(failures int) time.Duration {
+	r := Retrier{InitialBackoff: s.InitialBackoff, MaxBackoff: s.MaxBackoff}
+	if r.InitialBackoff <= 0 {
+		r.InitialBackoff = defaultStreamInitialBackoff
+	}
+	if r.MaxBackoff <= 0 {
+		r.MaxBackoff = defaultStreamMaxBackoff
+	}
+	return r.backoff(max(failures, 1))
+}
+
+const eventStreamContentType = "text/event-stream"
+
+// IsEventStream reports whether the headers indicate a Server-Sent Events stream, i.e. the request accepts, or the response
+// has, the text/event-stream content type. Cacher doesn't cache event streams and InstrumentedClient doesn't measure their
+// time to last byte.
+func IsEventStream(header http.Header) bool {
+	if mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type")); mediaType == eventStreamContentType {
+		return true
+	}
+	for _, value := range header.Values("Accept") {
+		for _, mediaRange := range strings.Split(value, ",") {
+			if mediaType, _, _ := mime.ParseMediaType(mediaRange); mediaType == eventStreamContentType {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// eventStream holds the state of the stream that survives reconnects
+type eventStream struct {
+	lastEventID string
+	retry       time.Duration
+}
+
+// read parses the events in r, as per the HTML Living Standard. The iterator yields an error if reading r fails, including io.EOF.
+func (s *eventStream) read(r io.Reader) iter.Seq2[Event, error] {
+	return func(yield func(Event, error) bool) {
+		reader := bufio.NewReader(r)
+		var event Event
+		var data strings.Builder
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				// an incomplete event is discarded
+				yield(Event{}, err)
+				return
+			}
+			line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+
+			if line == "" {
+				if data.Len() > 0 {
+					event.ID = s.lastEventID
+					event.Data = strings.TrimSuffix(data.String(), "\n")
+					if event.Event == "" {
+						event.Event = "message"
+					}
+					if !yield(event, nil) {
+						return
+					}
+				}
+				event = Event{}
+				data.Reset()
+				continue
+			}
+
+			field, value, _ := strings.Cut(line, ":")
+			value = strings.TrimPrefix(value, " ")
+			switch field {
+			case "":
+				// comment
+			case "event":
+				event.Event = value
+			case "data":
+				data.WriteString(value + "\n")
+			case "id":
+				if !strings.ContainsRune(value, 0) {
+					s.lastEventID = value
+				}
+			case "retry":
+				if ms, err := strconv.ParseUint(value, 10, 63); err == nil {
+					s.retry = time.Duration(ms) * time.Millisecond
+				}
+			}
+		}
+	}
+}
+
+// StreamMetrics contains Prometheus metrics recorded by EventSource.
+type StreamMetrics struct {
+	events     *prometheus.CounterVec // counts the received events
+	reconnects *prometheus.CounterVec // counts the reconnects
+}
+
+// NewStreamMetrics creates the Prometheus metrics recorded by EventSource.
+func NewStreamMetrics(namespace, subsystem string) *StreamMetrics {
+	return &StreamMetrics{
+		events: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: prometheus.BuildFQName(namespace, subsystem, "api_stream_events_total"),
+			Help: "Number of received Server-Sent Events, by event type",
+		}, []string{"application", "host", "event"}),
+		reconnects: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: prometheus.BuildFQName(namespace, subsystem, "api_stream_reconnects_total"),
+			Help: "Number of reconnects of Server-Sent Events streams",
+		}, []string{"application", "host"}),
+	}
+}
+
+var _ prometheus.Collector = &StreamMetrics{}
+
+// Describe implements the prometheus.Collector interface so clients can register StreamMetrics as a whole
+func (m *StreamMetrics) Describe(ch chan<- *prometheus.Desc) {
+	m.events.Describe(ch)
+	m.reconnects.Describe(ch)
+}
+
+// Collect implements the prometheus.Collector interface so clients can register StreamMetrics as a whole
+func (m *StreamMetrics) Collect(ch chan<- prometheus.Metric) {
+	m.events.Collect(ch)
+	m.reconnects.Collect(ch)
+}
+
+func (m *StreamMetrics) reportEvent(application, rawURL, event string) {
+	if m != nil {
+		m.events.WithLabelValues(application, urlHost(rawURL), event).Inc()
+	}
+}
+
+func (m *StreamMetrics) reportReconnect(application, rawURL string) {
+	if m != nil {
+		m.reconnects.WithLabelValues(application, urlHost(rawURL)).Inc()
+	}
+}
+
+func urlHost(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
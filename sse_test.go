@@ -0,0 +1,193 @@
+package httpclient_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/clambin/cache"
+	"github.com/clambin/httpclient"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestEventSource_Events(t *testing.T) {
+	var connections atomic.Int32
+	var lastEventIDs []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Accept") != "text/event-stream" {
+			http.Error(w, "invalid accept header", http.StatusBadRequest)
+			return
+		}
+		lastEventIDs = append(lastEventIDs, req.Header.Get("Last-Event-ID"))
+		w.Header().Set("Content-Type", "text/event-stream")
+		switch connections.Add(1) {
+		case 1:
+			_, _ = fmt.Fprint(w, "retry: 10\n: comment\n\ndata: foo\ndata: bar\nid: 1\n\n")
+			_, _ = fmt.Fprint(w, "event: update\r\ndata:baz\r\nid: 2\r\n\r\ndata: incomplete")
+		case 2:
+			_, _ = fmt.Fprint(w, "data: snafu\n\n")
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer s.Close()
+
+	r := prometheus.NewRegistry()
+	metrics := httpclient.NewStreamMetrics("foo", "")
+	r.MustRegister(metrics)
+
+	es := httpclient.EventSource{Caller: &httpclient.BaseClient{}, URL: s.URL, Metrics: metrics, Application: "foo"}
+	var events []httpclient.Event
+	for event, err := range es.Events(context.Background()) {
+		require.NoError(t, err)
+		events = append(events, event)
+	}
+
+	assert.Equal(t, []httpclient.Event{
+		{ID: "1", Event: "message", Data: "foo\nbar"},
+		{ID: "2", Event: "update", Data: "baz"},
+		{ID: "2", Event: "message", Data: "snafu"},
+	}, events)
+	assert.Equal(t, []string{"", "2", "2"}, lastEventIDs)
+	assert.Equal(t, 3.0, metricValue(t, r, "foo_api_stream_events_total"))
+	assert.Equal(t, 2.0, metricValue(t, r, "foo_api_stream_reconnects_total"))
+}
+
+func TestEventSource_Events_Failures(t *testing.T) {
+	var connections atomic.Int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		connections.Add(1)
+		switch req.URL.Path {
+		case "/unavailable":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case "/text":
+			_, _ = w.Write([]byte("hello"))
+		default:
+			http.Error(w, "invalid endpoint", http.StatusNotFound)
+		}
+	}))
+	defer s.Close()
+
+	for _, tc := range []struct {
+		path        string
+		connections int32
+		err         error
+	}{
+		{path: "/unavailable", connections: 3, err: httpclient.ErrServerError},
+		{path: "/invalid", connections: 1, err: httpclient.ErrNotFound},
+		{path: "/text", connections: 1},
+	} {
+		t.Run(tc.path, func(t *testing.T) {
+			connections.Store(0)
+			es := httpclient.EventSource{Caller: &httpclient.BaseClient{}, URL: s.URL + tc.path, InitialBackoff: time.Millisecond, MaxFailures: 3}
+			var errs []error
+			for _, err := range es.Events(context.Background()) {
+				errs = append(errs, err)
+			}
+			require.Len(t, errs, 1)
+			if tc.err != nil {
+				assert.ErrorIs(t, errs[0], tc.err)
+			} else {
+				assert.Error(t, errs[0])
+			}
+			assert.Equal(t, tc.connections, connections.Load())
+		})
+	}
+}
+
+func TestEventSource_Events_Cancel(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = fmt.Fprint(w, "data: foo\n\n")
+		w.(http.Flusher).Flush()
+		<-req.Context().Done()
+	}))
+	defer s.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	es := httpclient.EventSource{Caller: &httpclient.BaseClient{}, URL: s.URL}
+	var events int
+	var errs []error
+	for _, err := range es.Events(ctx) {
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		events++
+		cancel()
+	}
+	assert.Equal(t, 1, events)
+	require.Len(t, errs, 1)
+	assert.ErrorIs(t, errs[0], context.Canceled)
+
+	// breaking out of the loop closes the stream
+	for range es.Events(context.Background()) {
+		break
+	}
+}
+
+func TestEventStream_SkipsCacheAndTransfer(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = fmt.Fprint(w, "data: foo\n\n")
+	}))
+	defer s.Close()
+
+	r := prometheus.NewRegistry()
+	metrics := httpclient.NewMetrics("foo", "")
+	r.MustRegister(metrics)
+	store := cache.New[string, []byte](time.Minute, 0)
+	c := httpclient.Chain(&httpclient.BaseClient{},
+		httpclient.CachingMiddleware(nil, store),
+		httpclient.InstrumentationMiddleware("foo", httpclient.Options{PrometheusMetrics: metrics}),
+	)
+
+	req, _ := http.NewRequest(http.MethodGet, s.URL, nil)
+	resp, err := c.Do(req)
+	require.NoError(t, err)
+	_, _ = io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+
+	assert.Zero(t, store.Len())
+	assert.Equal(t, map[string]uint64{"": 1}, getLatencyCounters(t, r, "foo_"))
+	assert.Empty(t, getTransferCounters(t, r, "foo_"))
+}
+
+func TestCacher_Do_EventStreamAccept(t *testing.T) {
+	var calls int
+	store := cache.New[string, []byte](time.Minute, 0)
+	c := &httpclient.Cacher{
+		Caller: httpclient.CallerFunc(func(*http.Request) (*http.Response, error) {
+			calls++
+			return &http.Response{StatusCode: http.StatusOK, Header: http.Header{"Content-Type": {"application/json"}}, Body: http.NoBody}, nil
+		}),
+		Cache: store,
+	}
+
+	// a request accepting an event stream, among other media types, is never served from, or stored in, cache
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest(http.MethodGet, "http://localhost/events", nil)
+		req.Header.Set("Accept", "text/event-stream, application/json")
+		resp, err := c.Do(req)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+	}
+	assert.Equal(t, 2, calls)
+	assert.Zero(t, store.Len())
+}
+
+func TestIsEventStream(t *testing.T) {
+	assert.True(t, httpclient.IsEventStream(http.Header{"Content-Type": {"text/event-stream; charset=utf-8"}}))
+	assert.True(t, httpclient.IsEventStream(http.Header{"Accept": {"text/event-stream"}}))
+	assert.True(t, httpclient.IsEventStream(http.Header{"Accept": {"application/json, text/event-stream;q=0.9"}}))
+	assert.True(t, httpclient.IsEventStream(http.Header{"Accept": {"application/json", "text/event-stream"}}))
+	assert.False(t, httpclient.IsEventStream(http.Header{"Accept": {"application/json, text/plain"}}))
+	assert.False(t, httpclient.IsEventStream(http.Header{"Content-Type": {"application/json"}}))
+}
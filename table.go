@@ -80,18 +80,25 @@ func (entry CacheTableEntry) shouldCache(r *http.Request) (match bool, expiry ti
 }
 
 func (entry CacheTableEntry) matchesEndpoint(r *http.Request) bool {
-	endpoint := r.URL.Path
-	if entry.IsRegExp {
-		return entry.compiledRegExp.MatchString(endpoint)
-	}
-	return entry.Endpoint == endpoint
+	return matchesEndpoint(r, entry.Endpoint, entry.IsRegExp, entry.compiledRegExp)
 }
 
 func (entry CacheTableEntry) matchesMethods(r *http.Request) bool {
-	if len(entry.Methods) == 0 {
+	return matchesMethods(r, entry.Methods)
+}
+
+func matchesEndpoint(r *http.Request, endpoint string, isRegExp bool, compiledRegExp *regexp.Regexp) bool {
+	if isRegExp {
+		return compiledRegExp.MatchString(r.URL.Path)
+	}
+	return endpoint == r.URL.Path
+}
+
+func matchesMethods(r *http.Request, methods []string) bool {
+	if len(methods) == 0 {
 		return true
 	}
-	for _, method := range entry.Methods {
+	for _, method := range methods {
 		if method == r.Method {
 			return true
 		}
@@ -0,0 +1,140 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// TimeoutError is returned by TimeoutPolicy when a request failed because it exceeded the policy's timeout
+// (rather than the deadline of the request's context). It wraps the original error, so errors.Is(err, context.DeadlineExceeded) still holds.
+type TimeoutError struct {
+	// Endpoint is the Endpoint of the matching TimeoutTableEntry. Empty if the Default timeout was applied.
+	Endpoint string
+	// Timeout is the timeout that was applied
+	Timeout time.Duration
+	// Err is the original error
+	Err error
+}
+
+// Error returns the error message
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("timeout policy exceeded (%s): %s", e.Timeout, e.Err)
+}
+
+// Unwrap returns the original error
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// TimeoutTableEntry sets the timeout for requests matching its Endpoint and Methods. The matching rules are the same as for CacheTableEntry.
+type TimeoutTableEntry struct {
+	// Endpoint is the URL Path of the requests. Can be a literal path, or a regular expression. In the latter case, set IsRegExp to true
+	Endpoint string
+	// Methods is the list of HTTP Methods the timeout applies to. If empty, it applies to any method.
+	Methods []string
+	// IsRegExp indicated the Endpoint is a regular expression.
+	// Note: TimeoutPolicy will panic if Endpoint does not contain a valid regular expression.
+	IsRegExp bool
+	// Timeout is the time a request may take, including reading the response body.
+	Timeout        time.Duration
+	compiledRegExp *regexp.Regexp
+}
+
+// TimeoutPolicy implements the Caller interface. It applies a deadline to each request, based on the first matching entry in Table,
+// or Default if no entry matches. The deadline covers the complete request, including reading the response body.
+//
+// If the request's context already has an earlier deadline, that deadline applies. If the request fails because of the policy's
+// deadline, the error is a TimeoutError. If it fails because of the context's own deadline, the error is returned as-is.
+type TimeoutPolicy struct {
+	Caller
+	// Default is the timeout for requests that don't match any entry in Table. Zero means no timeout.
+	Default time.Duration
+	// Table holds the timeouts per endpoint
+	Table []TimeoutTableEntry
+
+	lock     sync.Mutex
+	compiled bool
+}
+
+var _ Caller = &TimeoutPolicy{}
+
+// Do sends the request, with a deadline as per the policy.
+func (p *TimeoutPolicy) Do(req *http.Request) (*http.Response, error) {
+	endpoint, timeout := p.timeout(req)
+	if timeout <= 0 {
+		return p.Caller.Do(req)
+	}
+
+	cause := fmt.Errorf("timeout policy: %w", context.DeadlineExceeded)
+	ctx, cancel := context.WithTimeoutCause(req.Context(), timeout, cause)
+	wrap := func(err error) error {
+		if err != nil && errors.Is(context.Cause(ctx), cause) && req.Context().Err() == nil {
+			return &TimeoutError{Endpoint: endpoint, Timeout: timeout, Err: err}
+		}
+		return err
+	}
+
+	resp, err := p.Caller.Do(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return resp, wrap(err)
+	}
+	resp.Body = &timeoutBody{ReadCloser: resp.Body, cancel: cancel, wrap: wrap}
+	return resp, nil
+}
+
+func (p *TimeoutPolicy) timeout(req *http.Request) (string, time.Duration) {
+	p.compileIfNeeded()
+	for _, entry := range p.Table {
+		if matchesEndpoint(req, entry.Endpoint, entry.IsRegExp, entry.compiledRegExp) && matchesMethods(req, entry.Methods) {
+			return entry.Endpoint, entry.Timeout
+		}
+	}
+	return "", p.Default
+}
+
+func (p *TimeoutPolicy) compileIfNeeded() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.compiled {
+		return
+	}
+	for index := range p.Table {
+		if p.Table[index].IsRegExp {
+			var err error
+			p.Table[index].compiledRegExp, err = regexp.Compile(p.Table[index].Endpoint)
+			if err != nil {
+				panic(fmt.Errorf("timeoutPolicy: invalid regexp '%s': %w", p.Table[index].Endpoint, err))
+			}
+		}
+	}
+	p.compiled = true
+}
+
+// timeoutBody releases the request's context when the body is closed, and reports read errors caused by the policy's deadline as a TimeoutError.
+type timeoutBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+	wrap   func(error) error
+}
+
+func (b *timeoutBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && !errors.Is(err, io.EOF) {
+		err = b.wrap(err)
+	}
+	return n, err
+}
+
+func (b *timeoutBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,96 @@
+package httpclient_test
+
+import (
+	"context"
+	"errors"
+	"github.com/clambin/httpclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimeoutPolicy_Do(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		delay, _ := time.ParseDuration(req.URL.Query().Get("delay"))
+		select {
+		case <-req.Context().Done():
+		case <-time.After(delay):
+		}
+		_, _ = w.Write([]byte("hello"))
+	}))
+	defer s.Close()
+
+	c := &httpclient.TimeoutPolicy{
+		Caller:  &httpclient.BaseClient{},
+		Default: 50 * time.Millisecond,
+		Table: []httpclient.TimeoutTableEntry{
+			{Endpoint: "/export/.*", IsRegExp: true, Timeout: time.Second},
+		},
+	}
+
+	for _, tc := range []struct {
+		name     string
+		path     string
+		deadline time.Duration
+		wantErr  bool
+		policy   bool
+		endpoint string
+	}{
+		{name: "default, in time", path: "/health?delay=1ms"},
+		{name: "default, too slow", path: "/health?delay=200ms", wantErr: true, policy: true},
+		{name: "endpoint, in time", path: "/export/all?delay=200ms"},
+		{name: "endpoint, too slow", path: "/export/all?delay=2s", wantErr: true, policy: true, endpoint: "/export/.*"},
+		{name: "caller's deadline", path: "/export/all?delay=500ms", deadline: 100 * time.Millisecond, wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tc.deadline > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tc.deadline)
+				defer cancel()
+			}
+			req, _ := http.NewRequestWithContext(ctx, http.MethodGet, s.URL+tc.path, nil)
+			resp, err := c.Do(req)
+			if !tc.wantErr {
+				require.NoError(t, err)
+				body, err := io.ReadAll(resp.Body)
+				require.NoError(t, err)
+				_ = resp.Body.Close()
+				assert.Equal(t, "hello", string(body))
+				return
+			}
+			require.Error(t, err)
+			assert.ErrorIs(t, err, context.DeadlineExceeded)
+			var timeoutErr *httpclient.TimeoutError
+			assert.Equal(t, tc.policy, errors.As(err, &timeoutErr))
+			if tc.policy {
+				assert.Equal(t, tc.endpoint, timeoutErr.Endpoint)
+			}
+		})
+	}
+}
+
+func TestTimeoutPolicy_Do_Body(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Length", "10")
+		_, _ = w.Write([]byte("hello"))
+		w.(http.Flusher).Flush()
+		<-req.Context().Done()
+	}))
+	defer s.Close()
+
+	c := &httpclient.TimeoutPolicy{Caller: &httpclient.BaseClient{}, Default: 100 * time.Millisecond}
+
+	req, _ := http.NewRequest(http.MethodGet, s.URL, nil)
+	resp, err := c.Do(req)
+	require.NoError(t, err)
+	_, err = io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	var timeoutErr *httpclient.TimeoutError
+	require.ErrorAs(t, err, &timeoutErr)
+	assert.Equal(t, 100*time.Millisecond, timeoutErr.Timeout)
+}
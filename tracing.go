@@ -0,0 +1,143 @@
+package httpclient
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"strconv"
+)
+
+const tracerName = "github.com/clambin/httpclient"
+
+// TracingClient implements the Caller interface. It creates an OpenTelemetry client span for each request, following the HTTP
+// semantic conventions, and propagates the trace context to the server through the request's headers.
+//
+// The span ends when the response body has been read completely, or is closed. When placed in front of a Cacher,
+// the span will also contain an event indicating whether the response was served from cache.
+type TracingClient struct {
+	Caller
+	// TracerProvider creates the tracer used to create spans. If nil, the global TracerProvider is used.
+	TracerProvider trace.TracerProvider
+	// Propagator injects the trace context in the request's headers. If nil, W3C Trace Context (traceparent/tracestate) is used.
+	Propagator propagation.TextMapPropagator
+	// EndpointNamer determines the url.template attribute and the span name. If nil, the span is named after the request's method only.
+	EndpointNamer EndpointNamer
+}
+
+var _ Caller = &TracingClient{}
+
+// Do creates a client span for the request, injects the trace context and sends the request.
+func (c *TracingClient) Do(req *http.Request) (resp *http.Response, err error) {
+	attributes := requestAttributes(req)
+	name := req.Method
+	if c.EndpointNamer != nil {
+		endpoint := c.EndpointNamer.EndpointName(req)
+		name += " " + endpoint
+		attributes = append(attributes, semconv.URLTemplate(endpoint))
+	}
+
+	ctx, span := c.tracer().Start(req.Context(), name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attributes...))
+
+	req = req.WithContext(ctx)
+	req.Header = req.Header.Clone()
+	if req.Header == nil {
+		req.Header = make(http.Header)
+	}
+	c.propagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	if resp, err = c.Caller.Do(req); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		span.SetAttributes(semconv.ErrorType(err))
+		span.End()
+		return resp, err
+	}
+
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+	if resp.StatusCode >= http.StatusBadRequest {
+		span.SetStatus(codes.Error, "")
+		span.SetAttributes(semconv.ErrorTypeKey.String(strconv.Itoa(resp.StatusCode)))
+	}
+	resp.Body = &instrumentedBody{
+		ReadCloser: resp.Body,
+		onDone: func(bodyErr error) {
+			if bodyErr != nil {
+				span.RecordError(bodyErr)
+				span.SetStatus(codes.Error, bodyErr.Error())
+			}
+			span.End()
+		},
+	}
+	return resp, nil
+}
+
+func (c *TracingClient) tracer() trace.Tracer {
+	tp := c.TracerProvider
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	return tp.Tracer(tracerName)
+}
+
+func (c *TracingClient) propagator() propagation.TextMapPropagator {
+	if c.Propagator == nil {
+		return propagation.TraceContext{}
+	}
+	return c.Propagator
+}
+
+var knownMethods = map[string]attribute.KeyValue{
+	http.MethodConnect: semconv.HTTPRequestMethodConnect,
+	http.MethodDelete:  semconv.HTTPRequestMethodDelete,
+	http.MethodGet:     semconv.HTTPRequestMethodGet,
+	http.MethodHead:    semconv.HTTPRequestMethodHead,
+	http.MethodOptions: semconv.HTTPRequestMethodOptions,
+	http.MethodPatch:   semconv.HTTPRequestMethodPatch,
+	http.MethodPost:    semconv.HTTPRequestMethodPost,
+	http.MethodPut:     semconv.HTTPRequestMethodPut,
+	http.MethodTrace:   semconv.HTTPRequestMethodTrace,
+}
+
+func methodAttributes(method string) []attribute.KeyValue {
+	if method == "" {
+		method = http.MethodGet
+	}
+	if attr, ok := knownMethods[method]; ok {
+		return []attribute.KeyValue{attr}
+	}
+	return []attribute.KeyValue{semconv.HTTPRequestMethodOther, semconv.HTTPRequestMethodOriginal(method)}
+}
+
+func requestAttributes(req *http.Request) []attribute.KeyValue {
+	attributes := methodAttributes(req.Method)
+
+	u := *req.URL
+	u.User = nil
+	attributes = append(attributes, semconv.URLFull(u.String()))
+
+	host, port := hostAndPort(req)
+	if host != "" {
+		attributes = append(attributes, semconv.ServerAddress(host))
+	}
+	if port > 0 {
+		attributes = append(attributes, semconv.ServerPort(port))
+	}
+	return attributes
+}
+
+func hostAndPort(req *http.Request) (host string, port int) {
+	host = req.URL.Hostname()
+	switch {
+	case req.URL.Port() != "":
+		port, _ = strconv.Atoi(req.URL.Port())
+	case req.URL.Scheme == "https":
+		port = 443
+	case req.URL.Scheme == "http":
+		port = 80
+	}
+	return host, port
+}
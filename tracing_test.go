@@ -0,0 +1,107 @@
+package httpclient_test
+
+import (
+	"github.com/clambin/cache"
+	"github.com/clambin/httpclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTracingClient_Do(t *testing.T) {
+	var traceparent string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		traceparent = req.Header.Get("traceparent")
+		handler(w, req)
+	}))
+	defer s.Close()
+
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	c := &httpclient.TracingClient{
+		Caller:         &httpclient.BaseClient{},
+		TracerProvider: tp,
+		EndpointNamer:  &httpclient.EndpointNames{},
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, s.URL+"/foo", nil)
+	_, err := doCall(c, req.URL.String())
+	require.NoError(t, err)
+	assert.Empty(t, req.Header.Get("traceparent"))
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	span := spans[0]
+	assert.Equal(t, "GET /foo", span.Name)
+	assert.Equal(t, trace.SpanKindClient, span.SpanKind)
+	assert.Equal(t, codes.Unset, span.Status.Code)
+	attrs := attributeMap(span.Attributes)
+	assert.Equal(t, "GET", attrs["http.request.method"])
+	assert.Equal(t, s.URL+"/foo", attrs["url.full"])
+	assert.Equal(t, "/foo", attrs["url.template"])
+	assert.Equal(t, "127.0.0.1", attrs["server.address"])
+	assert.Equal(t, int64(200), attrs["http.response.status_code"])
+	assert.Contains(t, traceparent, span.SpanContext.TraceID().String())
+	assert.Contains(t, traceparent, span.SpanContext.SpanID().String())
+
+	exporter.Reset()
+	req, _ = http.NewRequest(http.MethodGet, s.URL+"/bar", nil)
+	resp, err := c.Do(req)
+	require.NoError(t, err)
+	assert.Empty(t, exporter.GetSpans(), "span only ends when the body is closed")
+	_ = resp.Body.Close()
+	spans = exporter.GetSpans()
+	require.Len(t, spans, 1)
+	assert.Equal(t, codes.Error, spans[0].Status.Code)
+	assert.Equal(t, "404", attributeMap(spans[0].Attributes)["error.type"])
+
+	exporter.Reset()
+	_, err = doCall(c, "http://localhost:0/foo")
+	require.Error(t, err)
+	spans = exporter.GetSpans()
+	require.Len(t, spans, 1)
+	assert.Equal(t, codes.Error, spans[0].Status.Code)
+	assert.NotEmpty(t, spans[0].Events)
+}
+
+func TestTracingClient_Do_Cacher(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	exporter := tracetest.NewInMemoryExporter()
+	c := &httpclient.TracingClient{
+		Caller: &httpclient.Cacher{
+			Caller: &httpclient.BaseClient{},
+			Cache:  cache.New[string, []byte](time.Minute, 0),
+		},
+		TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)),
+	}
+
+	for i := 0; i < 2; i++ {
+		_, err := doCall(c, s.URL+"/foo")
+		require.NoError(t, err)
+	}
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 2)
+	require.Len(t, spans[0].Events, 1)
+	assert.Equal(t, "cache miss", spans[0].Events[0].Name)
+	require.Len(t, spans[1].Events, 1)
+	assert.Equal(t, "cache hit", spans[1].Events[0].Name)
+}
+
+func attributeMap(attributes []attribute.KeyValue) map[string]any {
+	m := make(map[string]any, len(attributes))
+	for _, attr := range attributes {
+		m[string(attr.Key)] = attr.Value.AsInterface()
+	}
+	return m
+}
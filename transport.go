@@ -0,0 +1,71 @@
+package httpclient
+
+import (
+	"io"
+	"net/http"
+	"sync"
+)
+
+// Transport implements the http.RoundTripper interface on top of a Caller. This allows the Caller's middleware
+// (e.g. caching or metrics) to be used by any http.Client:
+//
+//	client := &http.Client{Transport: &httpclient.Transport{Caller: c}}
+//
+// Transport passes a clone of the request to the Caller, so middleware modifying the request doesn't affect the original request.
+// As required by http.RoundTripper, it always closes the request's body, even when the Caller doesn't (e.g. when serving from cache).
+//
+// Since the http.Client already follows redirects, the Caller should not be based on a BaseClient. Use a RoundTripperCaller instead.
+type Transport struct {
+	Caller
+}
+
+var _ http.RoundTripper = &Transport{}
+
+// RoundTrip sends the request through the Caller.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	clone := req.Clone(req.Context())
+	if req.Body != nil {
+		body := &onceCloser{ReadCloser: req.Body}
+		clone.Body = body
+		defer func() { _ = body.Close() }()
+	}
+
+	resp, err := t.Caller.Do(clone)
+	if err != nil {
+		if resp != nil && resp.Body != nil {
+			_ = resp.Body.Close()
+		}
+		return nil, err
+	}
+	return resp, nil
+}
+
+// onceCloser makes sure the underlying ReadCloser is only closed once.
+type onceCloser struct {
+	io.ReadCloser
+	once sync.Once
+	err  error
+}
+
+func (c *onceCloser) Close() error {
+	c.once.Do(func() { c.err = c.ReadCloser.Close() })
+	return c.err
+}
+
+// RoundTripperCaller implements the Caller interface on top of an http.RoundTripper. This allows an existing transport
+// to be used as the base of a Chain. Unlike BaseClient, it sends each request as-is: it doesn't follow redirects or handle cookies.
+type RoundTripperCaller struct {
+	// RoundTripper sends the request. If nil, http.DefaultTransport is used.
+	RoundTripper http.RoundTripper
+}
+
+var _ Caller = &RoundTripperCaller{}
+
+// Do sends the request using the RoundTripper.
+func (c *RoundTripperCaller) Do(req *http.Request) (*http.Response, error) {
+	rt := c.RoundTripper
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	return rt.RoundTrip(req)
+}
@@ -0,0 +1,92 @@
+package httpclient_test
+
+import (
+	"github.com/clambin/cache"
+	"github.com/clambin/httpclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTransport_RoundTrip(t *testing.T) {
+	s := &server{}
+	srv := httptest.NewServer(http.HandlerFunc(s.handle))
+	defer srv.Close()
+
+	c := &http.Client{Transport: &httpclient.Transport{
+		Caller: httpclient.Chain(&httpclient.RoundTripperCaller{},
+			httpclient.CachingMiddleware(nil, cache.New[string, []byte](time.Minute, 0)),
+			func(next httpclient.Caller) httpclient.Caller {
+				return httpclient.CallerFunc(func(req *http.Request) (*http.Response, error) {
+					req.Header.Set("X-Test", "foo")
+					return next.Do(req)
+				})
+			},
+		),
+	}}
+
+	for i := 0; i < 2; i++ {
+		value, err := doCall2(httpclient.CallerFunc(func(req *http.Request) (*http.Response, error) {
+			resp, err := c.Do(req)
+			assert.Empty(t, req.Header.Get("X-Test"), "request should not be modified")
+			return resp, err
+		}), srv.URL+"/foo")
+		require.NoError(t, err)
+		assert.Equal(t, 1, value)
+	}
+}
+
+func TestTransport_RoundTrip_ClosesBody(t *testing.T) {
+	c := &httpclient.Transport{Caller: httpclient.CallerFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("ok"))}, nil
+	})}
+
+	body := &trackingBody{Reader: strings.NewReader("hello")}
+	req, _ := http.NewRequest(http.MethodPost, "http://localhost", body)
+	resp, err := c.RoundTrip(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, 1, body.closed)
+}
+
+func TestTransport_RoundTrip_Error(t *testing.T) {
+	respBody := &trackingBody{Reader: strings.NewReader("")}
+	c := &httpclient.Transport{Caller: httpclient.CallerFunc(func(req *http.Request) (*http.Response, error) {
+		_ = req.Body.Close()
+		return &http.Response{StatusCode: http.StatusFound, Body: respBody}, assert.AnError
+	})}
+
+	body := &trackingBody{Reader: strings.NewReader("hello")}
+	req, _ := http.NewRequest(http.MethodPost, "http://localhost", body)
+	resp, err := c.RoundTrip(req)
+	require.ErrorIs(t, err, assert.AnError)
+	assert.Nil(t, resp)
+	assert.Equal(t, 1, body.closed)
+	assert.Equal(t, 1, respBody.closed)
+}
+
+func TestRoundTripperCaller_Do(t *testing.T) {
+	srv := httptest.NewServer(http.RedirectHandler("/foo", http.StatusFound))
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	resp, err := (&httpclient.RoundTripperCaller{}).Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+}
+
+type trackingBody struct {
+	io.Reader
+	closed int
+}
+
+func (b *trackingBody) Close() error {
+	b.closed++
+	return nil
+}